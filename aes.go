@@ -5,6 +5,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"io"
 )
 
@@ -21,6 +22,9 @@ func (c *AESConfig) Encrypt(plaintext []byte) string {
 func (c *AESConfig) Decrypt(ciphertextHex string) (string, error) {
 	cipherData, _ := hex.DecodeString(ciphertextHex)
 	nonceSize := 12
+	if len(cipherData) < nonceSize {
+		return "", errors.New("ciphertext too short")
+	}
 	nonce, ciphertext := cipherData[:nonceSize], cipherData[nonceSize:]
 	plaintext, err := c.GCM.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
@@ -61,16 +61,67 @@ func NewLoginQR(level qrcode.RecoveryLevel) (*qrcode.QRCode, *LoginQRInfo, error
 	return qr, info, nil
 }
 
-// GetQRStatus 获取二维码状态，返回用于登录官网的 SESSDATA。
-// 轮询调用本方法可获取实时状态。状态分为未扫码(-3)、扫码未确认(-5)、扫码已确认(0)、二维码失效(-2)。
-// 扫码确认后返回一个 URL，请求该 URL 后 Set-Cookie 包含 SESSDATA，SESSDATA 用于官网登录。
-func GetQRStatus(oauthKey string) (*QRStatus, error) {
+// NewWebLoginQRInfo 创建官网登录二维码的信息，其中的 WebLoginQRInfo.URL 用于生成二维码。
+//
+// 与 [NewLoginQRInfo] 不同，本方法返回的 QRCodeKey 用于 [GetQRStatus] 轮询官网登录状态。
+func NewWebLoginQRInfo() (*WebLoginQRInfo, error) {
+	data, err := SimpleGet("https://passport.bilibili.com/x/passport-login/web/qrcode/generate")
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Data    WebLoginQRInfo `json:"data"`
+		Code    int            `json:"code"`
+		Message string         `json:"message"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Message)
+	}
+	return &res.Data, nil
+}
+
+type WebLoginQRInfo struct {
+	URL       string `json:"url"`
+	QRCodeKey string `json:"qrcode_key"`
+}
+
+// NewWebLoginQR 创建等待扫描的官网登录二维码，level 的含义同 [NewLoginQR]。
+func NewWebLoginQR(level qrcode.RecoveryLevel) (*qrcode.QRCode, *WebLoginQRInfo, error) {
+	info, err := NewWebLoginQRInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+	qr, err := qrcode.New(info.URL, level)
+	if err != nil {
+		return nil, nil, err
+	}
+	return qr, info, nil
+}
+
+// 官网登录二维码的状态码。
+const (
+	QRCodeSuccess     = 0
+	QRCodeExpired     = 86038
+	QRCodeUnconfirmed = 86090
+	QRCodeNotScanned  = 86101
+)
+
+// GetQRStatus 获取官网登录二维码状态，扫码确认后返回包含完整 Cookie 的 [Session]。
+//
+// 轮询调用本方法可获取实时状态。状态分为未扫码(86101)、扫码未确认(86090)、扫码已确认(0)、二维码失效(86038)。
+//
+// qrcodeKey 由 [NewWebLoginQR] 或 [NewWebLoginQRInfo] 返回。
+func GetQRStatus(qrcodeKey string) (*QRStatus, error) {
 	var res struct {
 		Data    QRStatus `json:"data"`
 		Code    int      `json:"code"`
 		Message string   `json:"message"`
 	}
-	data, _, cookies, err := SimpleRequest("GET", "https://passport.bilibili.com/x/passport-login/web/qrcode/poll?qrcode_key="+oauthKey, nil, nil)
+	data, _, cookies, err := SimpleRequest("GET", "https://passport.bilibili.com/x/passport-login/web/qrcode/poll?qrcode_key="+url.QueryEscape(qrcodeKey), nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -78,13 +129,19 @@ func GetQRStatus(oauthKey string) (*QRStatus, error) {
 	if err != nil {
 		return nil, err
 	}
-	sessdata, err := GetCookieValue(cookies, "SESSDATA")
-	if err == nil {
-		res.Data.SESSDATA = sessdata
-	}
 	if res.Code != 0 {
 		return nil, errors.New(res.Message)
 	}
+	if res.Data.Code == QRCodeExpired {
+		return nil, errors.New("二维码失效")
+	}
+	if res.Data.Success() {
+		session, err := NewSession(cookies, res.Data.RefreshToken)
+		if err != nil {
+			return nil, err
+		}
+		res.Data.Session = session
+	}
 	return &res.Data, nil
 }
 
@@ -98,15 +155,20 @@ func GetCookieValue(cookies []*http.Cookie, name string) (string, error) {
 	return "", errors.New("cookie with name " + name + " not found")
 }
 
-// QRStatus 二维码状态。
-// 状态分为未扫码(-3)、扫码未确认(-5)、扫码已确认(0)、二维码失效(-2)。
-// 扫码确认后，返回 TmpToken，用于获取用户登录后的数据。
+// QRStatus 官网登录二维码状态。
+// 状态分为未扫码(86101)、扫码未确认(86090)、扫码已确认(0)、二维码失效(86038)。
+// 扫码确认后，Session 包含用于官网登录的 Cookie 和 RefreshToken。
 type QRStatus struct {
-	Url          string `json:"string"`
-	RefreshToken string `json:"refresh_token"`
-	Code         int    `json:"code"`
-	Message      string `json:"message"`
-	SESSDATA     string
+	URL          string   `json:"url"`
+	RefreshToken string   `json:"refresh_token"`
+	Timestamp    int64    `json:"timestamp"`
+	Code         int      `json:"code"`
+	Message      string   `json:"message"`
+	Session      *Session `json:"-"`
+}
+
+func (s QRStatus) Success() bool {
+	return s.Code == QRCodeSuccess
 }
 
 // GetThirdQRStatus 获取二维码状态，返回 TmpToken。
@@ -152,49 +214,14 @@ type ThirdQRStatus struct {
 	} `json:"data"`
 }
 
+// ThirdQRCodeExpired 第三方登录二维码失效的状态码。
+const ThirdQRCodeExpired = -2
+
 func (t ThirdQRStatus) Success() bool {
 	return t.Code == 0
 }
 
-// GetAuthorizeCode 获取 Bilibili 重定向到三方地址时携带的 Code。
-//
-// clientId 哔哩哔哩开放平台申请应用时分配。
-//
-// tmpToken 扫码确认后由 [GetThirdQRStatus] 返回。
-//
-// returnURL 应用授权回调地址。该参数为创建应用时填写的「授权回调域」。
-//
-// 参数的详细说明，请参考 https://open.bilibili.com/doc/4/aac73b2e-4ff2-b75c-4c96-35ced865797b
-func GetAuthorizeCode(clientId string, tmpToken string, returnURL string) (codeInfo *AuthorizeCodeInfo, err error) {
-	body := url.Values{}
-	body.Set("client_id", clientId)
-	body.Set("tmp_token", tmpToken)
-	body.Set("scopes", "NFT_BASE,LIVER_BASE,FANS_BASE,USER_INFO")
-	body.Set("state", "1")
-	body.Set("return_url", returnURL)
-	data, err := SimplePost("https://api.bilibili.com/x/account-oauth2/v1/authorize", &body)
-	if err != nil {
-		return nil, err
-	}
-	var info AuthorizeCodeInfo
-	err = json.Unmarshal(data, &info)
-	if err != nil {
-		return nil, err
-	}
-	if info.Code != 0 {
-		return nil, errors.New(info.Message)
-	}
-	return &info, nil
-}
-
-type AuthorizeCodeInfo struct {
-	// 非 0 时表示操作失败
-	Code int `json:"code"`
-	// 操作失败时的提示文本
-	Message string `json:"message"`
-	Data    struct {
-		// Bilibili 重定向到三方地址时携带的 Code。
-		Code        string `json:"code"`
-		RedirectUrl string `json:"redirect_url"`
-	} `json:"data"`
+// Expired 判断二维码是否已经失效。
+func (t ThirdQRStatus) Expired() bool {
+	return t.Code == ThirdQRCodeExpired
 }
@@ -18,7 +18,7 @@ func TestLoginQRInfo(t *testing.T) {
 }
 
 func TestGetQRStatus(t *testing.T) {
-	qr, info, err := biliqr.NewLoginQR(qrcode.Low)
+	qr, info, err := biliqr.NewWebLoginQR(qrcode.Low)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -29,12 +29,12 @@ func TestGetQRStatus(t *testing.T) {
 		if i == 20 {
 			t.Fatal("timeout")
 		}
-		status, err := biliqr.GetQRStatus(info.OauthKey)
+		status, err := biliqr.GetQRStatus(info.QRCodeKey)
 		if err != nil {
 			t.Fatal(err)
 		}
-		if status.Code == 0 {
-			t.Log(status.SESSDATA)
+		if status.Success() {
+			t.Log(status.Session.SESSDATA, status.Session.BiliJct, status.Session.RefreshToken)
 			break
 		}
 		time.Sleep(time.Second)
@@ -0,0 +1,113 @@
+package biliqr
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WriteNetscapeCookies 以 Netscape cookies.txt 格式输出会话 Cookie，可用于 yt-dlp、curl 等工具。
+func (s *Session) WriteNetscapeCookies(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	b.WriteString("# https://curl.se/docs/http-cookies.html\n\n")
+	for _, cookie := range s.Cookies() {
+		domain := cookie.Domain
+		if cookie.HttpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		var expires int64
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\tTRUE\t%s\tFALSE\t%d\t%s\t%s\n", domain, cookie.Path, expires, cookie.Name, cookie.Value)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// CookieJar 返回预先载入会话 Cookie 的 [http.CookieJar]，作用域为 .bilibili.com。
+func (s *Session) CookieJar() (http.CookieJar, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	jar.SetCookies(&url.URL{Scheme: "https", Host: "www.bilibili.com", Path: "/"}, s.Cookies())
+	return jar, nil
+}
+
+// ParseNetscapeCookies 从 Netscape cookies.txt 格式的内容中读取 .bilibili.com 的 Cookie 并创建会话。
+func ParseNetscapeCookies(r io.Reader) (*Session, error) {
+	cookies := []*http.Cookie{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		line, httpOnly := strings.CutPrefix(line, "#HttpOnly_")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, errors.New("invalid cookies.txt line: " + line)
+		}
+		if !isBilibiliDomain(fields[0]) {
+			continue
+		}
+		cookie := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   fields[3] == "TRUE",
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if expires != 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewSession(cookies, "")
+}
+
+// isBilibiliDomain 判断 Cookie 的域名是否为 bilibili.com 或其子域名，不包括 evilbilibili.com 等相似域名。
+func isBilibiliDomain(domain string) bool {
+	domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+	return domain == "bilibili.com" || strings.HasSuffix(domain, ".bilibili.com")
+}
+
+// LoadCookiesFile 从文件读取会话，支持 Netscape cookies.txt 和 [Session.Save] 保存的 JSON。
+func LoadCookiesFile(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return LoadSession(path)
+	}
+	return ParseNetscapeCookies(bytes.NewReader(data))
+}
+
+// SaveNetscapeCookies 以 Netscape cookies.txt 格式保存会话 Cookie 到文件。
+func (s *Session) SaveNetscapeCookies(path string) error {
+	var b bytes.Buffer
+	if err := s.WriteNetscapeCookies(&b); err != nil {
+		return err
+	}
+	return os.WriteFile(path, b.Bytes(), 0600)
+}
@@ -0,0 +1,47 @@
+package biliqr
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// NavInfo 当前登录账号的基本信息。
+type NavInfo struct {
+	IsLogin bool   `json:"isLogin"`
+	Mid     int64  `json:"mid"`
+	Uname   string `json:"uname"`
+	Face    string `json:"face"`
+	// 会员类型，0：无，1：月度大会员，2：年度及以上大会员。
+	VipType int `json:"vipType"`
+	// 会员状态，0：无，1：有。
+	VipStatus int `json:"vipStatus"`
+	VipLabel  struct {
+		Text string `json:"text"`
+	} `json:"vip_label"`
+}
+
+// IsVip 判断当前账号是否为有效的大会员。
+func (n NavInfo) IsVip() bool {
+	return n.VipStatus == 1
+}
+
+// GetNavInfo 获取会话对应账号的昵称、mid、头像和大会员信息。
+func GetNavInfo(session *Session) (*NavInfo, error) {
+	data, _, _, err := SimpleRequest("GET", "https://api.bilibili.com/x/web-interface/nav", nil, session.Header())
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Data    NavInfo `json:"data"`
+		Code    int     `json:"code"`
+		Message string  `json:"message"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Message)
+	}
+	return &res.Data, nil
+}
@@ -0,0 +1,249 @@
+package biliqr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// DefaultScopes 默认申请的授权范围。
+var DefaultScopes = []string{"NFT_BASE", "LIVER_BASE", "FANS_BASE", "USER_INFO"}
+
+// DefaultTokenURL 哔哩哔哩开放平台使用 Code 换取 AccessToken 的接口。
+const DefaultTokenURL = "https://api.bilibili.com/x/account-oauth2/v1/token"
+
+// OAuthClient 基于扫码授权的 OAuth2 授权码模式客户端。
+//
+// 参数的详细说明，请参考 https://open.bilibili.com/doc/4/aac73b2e-4ff2-b75c-4c96-35ced865797b
+type OAuthClient struct {
+	// 哔哩哔哩开放平台申请应用时分配。
+	ClientID string
+	// 换取 AccessToken 时使用，仅授权时可以为空。
+	ClientSecret string
+	Scopes       []string
+	// 应用授权回调地址。该参数为创建应用时填写的「授权回调域」。
+	ReturnURL string
+	// 使用 Code 换取 AccessToken 的接口，为空时使用 [DefaultTokenURL]。
+	TokenURL string
+}
+
+// NewOAuthClient 创建使用 [DefaultScopes] 和 [DefaultTokenURL] 的客户端。
+func NewOAuthClient(clientId string, returnURL string) *OAuthClient {
+	return &OAuthClient{
+		ClientID:  clientId,
+		Scopes:    slices.Clone(DefaultScopes),
+		ReturnURL: returnURL,
+		TokenURL:  DefaultTokenURL,
+	}
+}
+
+// AuthorizeCode 获取 Bilibili 重定向到三方地址时携带的 Code，并校验返回的 state。
+//
+// tmpToken 扫码确认后由 [GetThirdQRStatus] 返回。
+func (c *OAuthClient) AuthorizeCode(tmpToken string) (*AuthorizeCodeInfo, error) {
+	body := url.Values{}
+	body.Set("tmp_token", tmpToken)
+	return c.authorize(body, http.Header{})
+}
+
+// AuthorizeCodeBySession 使用已登录的官网会话获取 Code，无需扫码。
+func (c *OAuthClient) AuthorizeCodeBySession(session *Session) (*AuthorizeCodeInfo, error) {
+	body := url.Values{}
+	body.Set("csrf", session.BiliJct)
+	return c.authorize(body, session.Header())
+}
+
+func (c *OAuthClient) authorize(body url.Values, header http.Header) (*AuthorizeCodeInfo, error) {
+	state, err := NewState()
+	if err != nil {
+		return nil, err
+	}
+	body.Set("client_id", c.ClientID)
+	body.Set("scopes", strings.Join(c.Scopes, ","))
+	body.Set("state", state)
+	body.Set("return_url", c.ReturnURL)
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	data, _, _, err := SimpleRequest("POST", "https://api.bilibili.com/x/account-oauth2/v1/authorize", strings.NewReader(body.Encode()), header)
+	if err != nil {
+		return nil, err
+	}
+	var info AuthorizeCodeInfo
+	err = json.Unmarshal(data, &info)
+	if err != nil {
+		return nil, err
+	}
+	if info.Code != 0 {
+		return nil, errors.New(info.Message)
+	}
+	if info.Data.RedirectUrl != "" {
+		if _, err = CheckRedirect(info.Data.RedirectUrl, state); err != nil {
+			return nil, err
+		}
+	}
+	info.State = state
+	return &info, nil
+}
+
+// AuthCodeURL 返回浏览器授权页面的地址，用户授权后跳转到 ReturnURL 并携带 code 和 state。
+func (c *OAuthClient) AuthCodeURL(state string) string {
+	query := url.Values{}
+	query.Set("client_id", c.ClientID)
+	query.Set("return_url", c.ReturnURL)
+	query.Set("response_type", "code")
+	query.Set("state", state)
+	return "https://account.bilibili.com/pc/account-pc/auth/oauth?" + query.Encode()
+}
+
+// OAuthToken 使用 Code 换取的 AccessToken。
+type OAuthToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// 有效期，单位为秒。
+	ExpiresIn int64    `json:"expires_in"`
+	Scopes    []string `json:"scopes"`
+}
+
+// Exchange 使用 Code 在 TokenURL 换取 AccessToken。
+//
+// 兼容哔哩哔哩开放平台 {code, message, data} 格式和标准 OAuth2 格式的响应。
+func (c *OAuthClient) Exchange(code string) (*OAuthToken, error) {
+	tokenURL := c.TokenURL
+	if tokenURL == "" {
+		tokenURL = DefaultTokenURL
+	}
+	body := url.Values{}
+	body.Set("client_id", c.ClientID)
+	body.Set("client_secret", c.ClientSecret)
+	body.Set("grant_type", "authorization_code")
+	body.Set("code", code)
+	body.Set("redirect_uri", c.ReturnURL)
+	data, err := SimplePost(tokenURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		OAuthToken
+		Data             *OAuthToken `json:"data"`
+		Code             int         `json:"code"`
+		Message          string      `json:"message"`
+		Error            string      `json:"error"`
+		ErrorDescription string      `json:"error_description"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Message)
+	}
+	if res.Error != "" {
+		return nil, errors.New(res.Error + ": " + res.ErrorDescription)
+	}
+	token := &res.OAuthToken
+	if res.Data != nil {
+		token = res.Data
+	}
+	if token.AccessToken == "" {
+		return nil, errors.New("access_token not found")
+	}
+	return token, nil
+}
+
+// NewState 生成随机的 state，用于校验授权回调。
+func NewState() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// CheckRedirect 校验回调地址中的 state，返回其中的 code。
+func CheckRedirect(redirectURL string, state string) (code string, err error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return "", err
+	}
+	return checkCallbackQuery(u.Query(), state)
+}
+
+func checkCallbackQuery(query url.Values, state string) (string, error) {
+	if query.Get("state") != state {
+		return "", errors.New("state mismatch")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("code not found")
+	}
+	return code, nil
+}
+
+// WaitForCallback 在 listener 上运行回调服务器，等待浏览器跳转到回调地址，校验 state 后返回 code。
+//
+// listener 的地址应与 ReturnURL 一致，例如 net.Listen("tcp", "127.0.0.1:8080")。
+func WaitForCallback(ctx context.Context, listener net.Listener, state string) (string, error) {
+	codes := make(chan string, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			code, err := checkCallbackQuery(r.URL.Query(), state)
+			if err != nil {
+				// 浏览器请求的 /favicon.ico 等无关请求不影响等待。
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.Write([]byte("授权成功，可以关闭此页面。"))
+			select {
+			case codes <- code:
+			default:
+			}
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Close()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case code := <-codes:
+		return code, nil
+	}
+}
+
+// GetAuthorizeCode 获取 Bilibili 重定向到三方地址时携带的 Code。
+//
+// clientId 哔哩哔哩开放平台申请应用时分配。
+//
+// tmpToken 扫码确认后由 [GetThirdQRStatus] 返回。
+//
+// returnURL 应用授权回调地址。该参数为创建应用时填写的「授权回调域」。
+//
+// 需要自定义授权范围时，请使用 [OAuthClient]。
+func GetAuthorizeCode(clientId string, tmpToken string, returnURL string) (codeInfo *AuthorizeCodeInfo, err error) {
+	return NewOAuthClient(clientId, returnURL).AuthorizeCode(tmpToken)
+}
+
+// GetAuthorizeCodeBySession 使用已登录的官网会话获取 Code，无需扫码。参数的含义同 [GetAuthorizeCode]。
+func GetAuthorizeCodeBySession(session *Session, clientId string, returnURL string) (*AuthorizeCodeInfo, error) {
+	return NewOAuthClient(clientId, returnURL).AuthorizeCodeBySession(session)
+}
+
+type AuthorizeCodeInfo struct {
+	// 非 0 时表示操作失败
+	Code int `json:"code"`
+	// 操作失败时的提示文本
+	Message string `json:"message"`
+	Data    struct {
+		// Bilibili 重定向到三方地址时携带的 Code。
+		Code        string `json:"code"`
+		RedirectUrl string `json:"redirect_url"`
+	} `json:"data"`
+	// 本次授权使用的 state，已在返回时校验。
+	State string `json:"-"`
+}
@@ -0,0 +1,74 @@
+package biliqr_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iuroc/gododo/biliqr"
+)
+
+func TestCheckRedirect(t *testing.T) {
+	code, err := biliqr.CheckRedirect("https://example.com/cb?code=abc&state=s1", "s1")
+	if err != nil || code != "abc" {
+		t.Fatal(code, err)
+	}
+	if _, err = biliqr.CheckRedirect("https://example.com/cb?code=abc&state=1", "s1"); err == nil {
+		t.Fatal("未检查出错误的 state")
+	}
+}
+
+func TestExchange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("code") != "abc" || r.Form.Get("client_secret") != "secret" {
+			w.Write([]byte(`{"code":-400,"message":"bad code"}`))
+			return
+		}
+		w.Write([]byte(`{"code":0,"data":{"access_token":"at","refresh_token":"rt","expires_in":3600}}`))
+	}))
+	defer server.Close()
+	client := biliqr.NewOAuthClient("id", "https://example.com/cb")
+	client.ClientSecret = "secret"
+	client.TokenURL = server.URL
+	token, err := client.Exchange("abc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "at" || token.RefreshToken != "rt" || token.ExpiresIn != 3600 {
+		t.Fatalf("%#v", token)
+	}
+	if _, err = client.Exchange("wrong"); err == nil {
+		t.Fatal("未检查出错误的 code")
+	}
+}
+
+func TestWaitForCallback(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		base := "http://" + listener.Addr().String()
+		http.Get(base + "/favicon.ico")
+		http.Get(base + "/cb?code=bad&state=other")
+		http.Get(base + "/cb?code=abc&state=s1")
+	}()
+	code, err := biliqr.WaitForCallback(ctx, listener, "s1")
+	if err != nil || code != "abc" {
+		t.Fatal(code, err)
+	}
+}
+
+func TestNewOAuthClientScopes(t *testing.T) {
+	client := biliqr.NewOAuthClient("id", "http://127.0.0.1/")
+	client.Scopes[0] = "CHANGED"
+	if biliqr.DefaultScopes[0] == "CHANGED" {
+		t.Fatal("修改 Scopes 影响了 DefaultScopes")
+	}
+}
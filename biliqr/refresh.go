@@ -0,0 +1,245 @@
+package biliqr
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 用于生成 CorrespondPath 的公钥。
+//
+// 刷新流程参考 https://socialsisteryi.github.io/bilibili-API-collect/docs/login/cookie_refresh.html
+const refreshPublicKey = `-----BEGIN PUBLIC KEY-----
+MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQDLgd2OAkcGVtoE3ThUREbio0Eg
+Uc/prcajMKXvkCKFCWhJYJcLkcM2DKKcSeFpD/j6Boy538YXnR6VhcuUJOhH2x71
+nzPjfdTcqMz7djHum0qSZA0AyCBDABUqCrfNgCiJ00Ra7GmRj+YCK1NJEuewlb40
+JNrRuoEUXpabUzGB8QIDAQAB
+-----END PUBLIC KEY-----`
+
+// CookieInfo 当前 Cookie 的刷新状态。
+type CookieInfo struct {
+	// 为 true 时需要刷新 Cookie。
+	Refresh bool `json:"refresh"`
+	// 毫秒时间戳，用于生成 CorrespondPath。
+	Timestamp int64 `json:"timestamp"`
+}
+
+// CookieInfo 检查当前会话的 Cookie 是否需要刷新。
+func (s *Session) CookieInfo() (*CookieInfo, error) {
+	data, _, _, err := SimpleRequest("GET", "https://passport.bilibili.com/x/passport-login/web/cookie/info?csrf="+url.QueryEscape(s.BiliJct), nil, s.Header())
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Data    CookieInfo `json:"data"`
+		Code    int        `json:"code"`
+		Message string     `json:"message"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Message)
+	}
+	return &res.Data, nil
+}
+
+// Refresh 使用 RefreshToken 换取新的 Cookie，并确认刷新使旧的 RefreshToken 失效。
+//
+// timestamp 由 [Session.CookieInfo] 返回。
+func (s *Session) Refresh(timestamp int64) (*Session, error) {
+	return s.refresh(timestamp, nil)
+}
+
+// refresh 刷新 Cookie，save 不为空时在确认刷新之前保存新会话，保存失败时不确认刷新，旧的会话仍然有效。
+func (s *Session) refresh(timestamp int64, save func(*Session) error) (*Session, error) {
+	correspondPath, err := CorrespondPath(timestamp)
+	if err != nil {
+		return nil, err
+	}
+	refreshCsrf, err := s.refreshCsrf(correspondPath)
+	if err != nil {
+		return nil, err
+	}
+	body := url.Values{
+		"csrf":          {s.BiliJct},
+		"refresh_csrf":  {refreshCsrf},
+		"source":        {"main_web"},
+		"refresh_token": {s.RefreshToken},
+	}
+	data, _, cookies, err := SimpleRequest("POST", "https://passport.bilibili.com/x/passport-login/web/cookie/refresh", strings.NewReader(body.Encode()), s.formHeader())
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Data struct {
+			RefreshToken string `json:"refresh_token"`
+		} `json:"data"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Message)
+	}
+	session, err := NewSession(cookies, res.Data.RefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	if save != nil {
+		if err = save(session); err != nil {
+			return nil, err
+		}
+	}
+	if err = session.confirmRefresh(s.RefreshToken); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// RefreshIfNeeded 在 Cookie 需要刷新时执行刷新，返回可用的会话以及是否发生了刷新。
+func (s *Session) RefreshIfNeeded() (*Session, bool, error) {
+	return s.refreshIfNeeded(nil)
+}
+
+func (s *Session) refreshIfNeeded(save func(*Session) error) (*Session, bool, error) {
+	info, err := s.CookieInfo()
+	if err != nil {
+		return nil, false, err
+	}
+	if !info.Refresh {
+		return s, false, nil
+	}
+	session, err := s.refresh(info.Timestamp, save)
+	if err != nil {
+		return nil, false, err
+	}
+	return session, true, nil
+}
+
+// refreshCsrf 请求 CorrespondPath 对应的页面，获取 refresh_csrf。
+func (s *Session) refreshCsrf(correspondPath string) (string, error) {
+	data, _, _, err := SimpleRequest("GET", "https://www.bilibili.com/correspond/1/"+correspondPath, nil, s.Header())
+	if err != nil {
+		return "", err
+	}
+	match := regexp.MustCompile(`<div id="1-name">(.+?)</div>`).FindSubmatch(data)
+	if len(match) != 2 {
+		return "", errors.New("refresh_csrf not found")
+	}
+	return string(match[1]), nil
+}
+
+// confirmRefresh 使用新会话确认刷新，oldRefreshToken 为刷新前的 RefreshToken。
+func (s *Session) confirmRefresh(oldRefreshToken string) error {
+	body := url.Values{
+		"csrf":          {s.BiliJct},
+		"refresh_token": {oldRefreshToken},
+	}
+	data, _, _, err := SimpleRequest("POST", "https://passport.bilibili.com/x/passport-login/web/confirm/refresh", strings.NewReader(body.Encode()), s.formHeader())
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return err
+	}
+	if res.Code != 0 {
+		return errors.New(res.Message)
+	}
+	return nil
+}
+
+func (s *Session) formHeader() http.Header {
+	header := s.Header()
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return header
+}
+
+// CorrespondPath 使用公钥加密 refresh_{timestamp}，返回小写 Hex。
+func CorrespondPath(timestamp int64) (string, error) {
+	block, _ := pem.Decode([]byte(refreshPublicKey))
+	if block == nil {
+		return "", errors.New("invalid public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return "", err
+	}
+	publicKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return "", errors.New("invalid public key")
+	}
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, []byte("refresh_"+strconv.FormatInt(timestamp, 10)), nil)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(ciphertext), nil
+}
+
+// LoadSession 从 JSON 文件读取会话。
+func LoadSession(path string) (*Session, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	err = json.Unmarshal(data, &session)
+	if err != nil {
+		return nil, err
+	}
+	if session.SESSDATA == "" {
+		return nil, errors.New("cookie with name SESSDATA not found")
+	}
+	return &session, nil
+}
+
+// Save 以 JSON 格式保存会话到文件，先写入临时文件再替换，避免中断时留下不完整的文件。
+func (s *Session) Save(path string) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	temp := path + ".tmp"
+	if err = os.WriteFile(temp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(temp, path)
+}
+
+// KeepSessionAlive 读取会话文件，在需要时刷新 Cookie 并写回文件。
+//
+// 定期调用本方法即可长期保持会话有效，无需重新扫码。新会话在确认刷新之前写回文件，
+// 确认刷新后旧的 RefreshToken 失效，因此写入失败时不会确认刷新。
+func KeepSessionAlive(path string) (*Session, error) {
+	session, err := LoadSession(path)
+	if err != nil {
+		return nil, err
+	}
+	session, _, err = session.refreshIfNeeded(func(session *Session) error {
+		return session.Save(path)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
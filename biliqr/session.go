@@ -0,0 +1,82 @@
+package biliqr
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Session 官网登录会话，由 [GetQRStatus] 在扫码确认后返回。
+type Session struct {
+	SESSDATA        string `json:"SESSDATA"`
+	BiliJct         string `json:"bili_jct"`
+	DedeUserID      string `json:"DedeUserID"`
+	DedeUserIDCkMd5 string `json:"DedeUserID__ckMd5"`
+	SID             string `json:"sid"`
+	// 用于刷新 Cookie。
+	RefreshToken string `json:"refresh_token"`
+	// SESSDATA 的过期时间。
+	Expires time.Time `json:"expires"`
+}
+
+// NewSession 从登录接口返回的 Cookie 中创建会话，Cookie 中必须包含 SESSDATA。
+func NewSession(cookies []*http.Cookie, refreshToken string) (*Session, error) {
+	session := &Session{RefreshToken: refreshToken}
+	for _, cookie := range cookies {
+		switch cookie.Name {
+		case "SESSDATA":
+			session.SESSDATA = cookie.Value
+			session.Expires = cookie.Expires
+		case "bili_jct":
+			session.BiliJct = cookie.Value
+		case "DedeUserID":
+			session.DedeUserID = cookie.Value
+		case "DedeUserID__ckMd5":
+			session.DedeUserIDCkMd5 = cookie.Value
+		case "sid":
+			session.SID = cookie.Value
+		}
+	}
+	if session.SESSDATA == "" {
+		return nil, errors.New("cookie with name SESSDATA not found")
+	}
+	return session, nil
+}
+
+// Cookies 返回会话的全部 Cookie，作用域为 .bilibili.com。
+func (s *Session) Cookies() []*http.Cookie {
+	values := [][2]string{
+		{"SESSDATA", s.SESSDATA},
+		{"bili_jct", s.BiliJct},
+		{"DedeUserID", s.DedeUserID},
+		{"DedeUserID__ckMd5", s.DedeUserIDCkMd5},
+		{"sid", s.SID},
+	}
+	cookies := []*http.Cookie{}
+	for _, item := range values {
+		if item[1] == "" {
+			continue
+		}
+		cookies = append(cookies, &http.Cookie{
+			Name:     item[0],
+			Value:    item[1],
+			Domain:   ".bilibili.com",
+			Path:     "/",
+			Expires:  s.Expires,
+			HttpOnly: item[0] == "SESSDATA",
+		})
+	}
+	return cookies
+}
+
+// Header 返回携带会话 Cookie 的请求头。
+func (s *Session) Header() http.Header {
+	pairs := []string{}
+	for _, cookie := range s.Cookies() {
+		pairs = append(pairs, cookie.Name+"="+cookie.Value)
+	}
+	header := http.Header{}
+	header.Set("Cookie", strings.Join(pairs, "; "))
+	return header
+}
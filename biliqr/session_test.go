@@ -0,0 +1,109 @@
+package biliqr_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iuroc/gododo/biliqr"
+)
+
+func TestNewSession(t *testing.T) {
+	_, err := biliqr.NewSession([]*http.Cookie{{Name: "bili_jct", Value: "jct"}}, "")
+	if err == nil {
+		t.Fatal("未检查出缺少 SESSDATA")
+	}
+	session, err := biliqr.NewSession([]*http.Cookie{
+		{Name: "SESSDATA", Value: "sess"},
+		{Name: "bili_jct", Value: "jct"},
+		{Name: "DedeUserID", Value: "123"},
+	}, "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.BiliJct != "jct" || session.DedeUserID != "123" || session.RefreshToken != "refresh" {
+		t.Fatalf("%#v", session)
+	}
+	if len(session.Cookies()) != 3 {
+		t.Fatal(session.Cookies())
+	}
+	if cookie := session.Header().Get("Cookie"); cookie != "SESSDATA=sess; bili_jct=jct; DedeUserID=123" {
+		t.Fatal(cookie)
+	}
+}
+
+func TestCorrespondPath(t *testing.T) {
+	path, err := biliqr.CorrespondPath(1684466537285)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(path) != 256 {
+		t.Fatal(path)
+	}
+}
+
+func TestSessionSave(t *testing.T) {
+	path := t.TempDir() + "/session.json"
+	session := &biliqr.Session{SESSDATA: "sess", RefreshToken: "refresh"}
+	if err := session.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := biliqr.LoadSession(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.SESSDATA != "sess" || loaded.RefreshToken != "refresh" {
+		t.Fatalf("%#v", loaded)
+	}
+}
+
+func TestNetscapeCookies(t *testing.T) {
+	session := &biliqr.Session{
+		SESSDATA:   "sess",
+		BiliJct:    "jct",
+		DedeUserID: "123",
+		Expires:    time.Unix(1893456000, 0),
+	}
+	var b bytes.Buffer
+	if err := session.WriteNetscapeCookies(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "#HttpOnly_.bilibili.com\tTRUE\t/\tFALSE\t1893456000\tSESSDATA\tsess\n") {
+		t.Fatal(b.String())
+	}
+	parsed, err := biliqr.ParseNetscapeCookies(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.SESSDATA != "sess" || parsed.BiliJct != "jct" || parsed.DedeUserID != "123" || !parsed.Expires.Equal(session.Expires) {
+		t.Fatalf("%#v", parsed)
+	}
+}
+
+func TestNetscapeCookiesDomain(t *testing.T) {
+	text := ".evilbilibili.com\tTRUE\t/\tFALSE\t0\tSESSDATA\tevil\n" +
+		"passport.bilibili.com\tFALSE\t/\tFALSE\t0\tSESSDATA\tsess\n" +
+		".bilibili.com.evil.com\tTRUE\t/\tFALSE\t0\tbili_jct\tevil\n"
+	parsed, err := biliqr.ParseNetscapeCookies(strings.NewReader(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.SESSDATA != "sess" || parsed.BiliJct != "" {
+		t.Fatalf("%#v", parsed)
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	session := &biliqr.Session{SESSDATA: "sess", BiliJct: "jct"}
+	jar, err := session.CookieJar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies := jar.Cookies(&url.URL{Scheme: "https", Host: "api.bilibili.com", Path: "/x/web-interface/nav"})
+	if len(cookies) != 2 {
+		t.Fatal(cookies)
+	}
+}
@@ -0,0 +1,189 @@
+package biliqr
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// TV 端的 appkey 和 appsec，用于 TV 扫码登录和 AccessToken 刷新。
+const (
+	TVAppKey = "4409e2ce8ffd12b8"
+	TVAppSec = "59b43e04ad6965f34319062b478f83dd"
+)
+
+// TV 登录二维码的状态码。
+const (
+	TVQRCodeSuccess     = 0
+	TVQRCodeExpired     = 86038
+	TVQRCodeNotScanned  = 86039
+	TVQRCodeUnconfirmed = 86090
+)
+
+// SignParams 为参数添加 appkey 和 ts，并按 APP 签名规则计算 sign。
+//
+// sign 为按 key 排序后的参数字符串拼接 appSec 后的 MD5 Hex。
+func SignParams(params url.Values, appKey string, appSec string) url.Values {
+	signed := url.Values{}
+	for key, values := range params {
+		signed[key] = values
+	}
+	signed.Set("appkey", appKey)
+	if signed.Get("ts") == "" {
+		signed.Set("ts", strconv.FormatInt(time.Now().Unix(), 10))
+	}
+	signed.Del("sign")
+	hash := md5.Sum([]byte(signed.Encode() + appSec))
+	signed.Set("sign", hex.EncodeToString(hash[:]))
+	return signed
+}
+
+// NewTVLoginQRInfo 创建 TV 登录二维码的信息，其中的 TVLoginQRInfo.URL 用于生成二维码。
+func NewTVLoginQRInfo() (*TVLoginQRInfo, error) {
+	body := SignParams(url.Values{"local_id": {"0"}}, TVAppKey, TVAppSec)
+	data, err := SimplePost("https://passport.bilibili.com/x/passport-tv-login/qrcode/auth_code", &body)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Data    TVLoginQRInfo `json:"data"`
+		Code    int           `json:"code"`
+		Message string        `json:"message"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Message)
+	}
+	return &res.Data, nil
+}
+
+type TVLoginQRInfo struct {
+	URL      string `json:"url"`
+	AuthCode string `json:"auth_code"`
+}
+
+// NewTVLoginQR 创建等待扫描的 TV 登录二维码，level 的含义同 [NewLoginQR]。
+func NewTVLoginQR(level qrcode.RecoveryLevel) (*qrcode.QRCode, *TVLoginQRInfo, error) {
+	info, err := NewTVLoginQRInfo()
+	if err != nil {
+		return nil, nil, err
+	}
+	qr, err := qrcode.New(info.URL, level)
+	if err != nil {
+		return nil, nil, err
+	}
+	return qr, info, nil
+}
+
+// GetTVQRStatus 获取 TV 登录二维码状态，扫码确认后返回 [AppToken]。
+//
+// 轮询调用本方法可获取实时状态。状态分为未扫码(86039)、扫码未确认(86090)、扫码已确认(0)、二维码失效(86038)。
+//
+// authCode 由 [NewTVLoginQR] 或 [NewTVLoginQRInfo] 返回。
+func GetTVQRStatus(authCode string) (*TVQRStatus, error) {
+	body := SignParams(url.Values{
+		"auth_code": {authCode},
+		"local_id":  {"0"},
+	}, TVAppKey, TVAppSec)
+	data, err := SimplePost("https://passport.bilibili.com/x/passport-tv-login/qrcode/poll", &body)
+	if err != nil {
+		return nil, err
+	}
+	return parseTVQRStatus(data)
+}
+
+// parseTVQRStatus 解析 TV 登录二维码状态接口的响应。
+func parseTVQRStatus(data []byte) (*TVQRStatus, error) {
+	var res struct {
+		Data    *AppToken `json:"data"`
+		Code    int       `json:"code"`
+		Message string    `json:"message"`
+	}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	switch res.Code {
+	case TVQRCodeSuccess:
+		if res.Data == nil || res.Data.AccessToken == "" {
+			return nil, errors.New("扫码已确认，但没有返回登录凭证")
+		}
+		res.Data.setExpires()
+		return &TVQRStatus{Code: res.Code, Message: "扫码已确认", Token: res.Data}, nil
+	case TVQRCodeNotScanned:
+		return &TVQRStatus{Code: res.Code, Message: "未扫码"}, nil
+	case TVQRCodeUnconfirmed:
+		return &TVQRStatus{Code: res.Code, Message: "扫码未确认"}, nil
+	case TVQRCodeExpired:
+		return nil, errors.New("二维码失效")
+	}
+	return nil, errors.New(res.Message)
+}
+
+// TVQRStatus TV 登录二维码状态，扫码确认后 Token 不为空。
+type TVQRStatus struct {
+	Code    int
+	Message string
+	Token   *AppToken
+}
+
+func (s TVQRStatus) Success() bool {
+	return s.Code == TVQRCodeSuccess
+}
+
+// AppToken APP 端的登录凭证，AccessToken 即请求参数中的 access_key。
+type AppToken struct {
+	Mid          int64  `json:"mid"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	// 有效期，单位为秒。
+	ExpiresIn int64 `json:"expires_in"`
+	// 根据 ExpiresIn 计算的过期时间。
+	Expires time.Time `json:"expires"`
+}
+
+func (t *AppToken) setExpires() {
+	t.Expires = time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
+// Expired 判断 AccessToken 是否已经过期或将在 within 时间内过期。
+func (t *AppToken) Expired(within time.Duration) bool {
+	return time.Now().Add(within).After(t.Expires)
+}
+
+// Refresh 使用 RefreshToken 换取新的 AccessToken。
+func (t *AppToken) Refresh() (*AppToken, error) {
+	body := SignParams(url.Values{
+		"access_key":    {t.AccessToken},
+		"refresh_token": {t.RefreshToken},
+	}, TVAppKey, TVAppSec)
+	data, err := SimplePost("https://passport.bilibili.com/x/passport-login/oauth2/refresh_token", &body)
+	if err != nil {
+		return nil, err
+	}
+	var res struct {
+		Data struct {
+			TokenInfo AppToken `json:"token_info"`
+		} `json:"data"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	err = json.Unmarshal(data, &res)
+	if err != nil {
+		return nil, err
+	}
+	if res.Code != 0 {
+		return nil, errors.New(res.Message)
+	}
+	token := res.Data.TokenInfo
+	token.setExpires()
+	return &token, nil
+}
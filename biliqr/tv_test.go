@@ -0,0 +1,37 @@
+package biliqr
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseTVQRStatus(t *testing.T) {
+	status, err := parseTVQRStatus([]byte(`{"code":0,"data":{"mid":1,"access_token":"a","refresh_token":"r","expires_in":60}}`))
+	if err != nil || status.Token.AccessToken != "a" || status.Token.Expires.IsZero() {
+		t.Fatal(status, err)
+	}
+	for _, data := range []string{`{"code":0}`, `{"code":0,"data":null}`, `{"code":0,"data":{}}`, `{"code":86038}`, `{"code":-400,"message":"bad"}`} {
+		if status, err := parseTVQRStatus([]byte(data)); err == nil {
+			t.Errorf("parseTVQRStatus(%s) = %+v", data, status)
+		}
+	}
+	if status, err := parseTVQRStatus([]byte(`{"code":86039}`)); err != nil || status.Code != TVQRCodeNotScanned {
+		t.Error(status, err)
+	}
+}
+
+func TestSignParams(t *testing.T) {
+	params := url.Values{
+		"id":   {"114514"},
+		"str":  {"1919810"},
+		"test": {"いいよ，こいよ"},
+		"ts":   {"1702204169"},
+	}
+	signed := SignParams(params, "1d8b6e7d45233436", "560c52ccd288fed045859ed18bffd973")
+	if signed.Get("appkey") != "1d8b6e7d45233436" || signed.Get("sign") != "d54317b2dea8f9df3a14f02aeddc2b20" {
+		t.Fatal(signed)
+	}
+	if params.Get("sign") != "" {
+		t.Fatal("SignParams 修改了传入的参数")
+	}
+}
@@ -0,0 +1,493 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/iuroc/gododo/biliqr"
+	"github.com/iuroc/gododo/dodo"
+)
+
+// 命令行退出码。
+const (
+	// 全部成功。
+	ExitOK = 0
+	// 一般错误。
+	ExitError = 1
+	// 命令或参数错误。
+	ExitUsage = 2
+	// 未登录或登录已失效，需要运行 gododo login。
+	ExitAuth = 3
+	// 文件不存在，或文件没有上传记录。
+	ExitNotFound = 4
+	// 至少一个文件上传失败。
+	ExitUpload = 5
+)
+
+// Command 子命令。
+type Command struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(args []string) int
+}
+
+// Commands 全部子命令，按帮助信息中的顺序排列。
+var Commands []*Command
+
+func init() {
+	Commands = []*Command{
+		{"upload", "upload [-q] [--json] [--format f] [--qr] [--qr-png] [--qr-dir dir] files...", "上传文件并输出直链", cmdUpload},
+		{"login", "login [--cookies file]", "扫码或使用 Cookie 文件登录", cmdLogin},
+		{"logout", "logout", "退出登录并删除保存的用户信息", cmdLogout},
+		{"whoami", "whoami [--json]", "输出当前登录的账号", cmdWhoami},
+		{"history", "history [-n count] [--json] [--format f] [files...]", "输出上传历史，或查询文件是否已上传", cmdHistory},
+		{"md", "md rewrite [-o path] [-i] [--dry-run] [--report path] [--allow-outside] doc.md", "上传 Markdown 引用的本地文件并替换为直链", cmdMarkdown},
+		{"offload", "offload -o dir [--min-size 1M] [--manifest path] [--keep-offloaded] [--dry-run] site", "上传静态网站中较大的媒体文件并替换引用", cmdOffload},
+		{"hls", "hls [-j jobs] [--manifest path] [--dry-run] playlist.m3u8", "上传 HLS 播放列表引用的分片并输出主播放列表直链", cmdHLS},
+		{"feed", "feed [-c config.json] [-o feed.xml] [--m3u path] [--upload] [--dry-run] files...", "生成播客 RSS 订阅和 M3U 播放列表", cmdFeed},
+		{"lfs-agent", "lfs-agent [--map path]", "Git LFS 自定义传输代理，由 git-lfs 调用", cmdLFSAgent},
+		{"serve", "serve [--addr host:port] [--key key] [--keys-file path]", "启动 HTTP 上传网关", cmdServe},
+		{"s3", "s3 [--addr host:port] [--key ak:sk] [--keys-file path] [--index path]", "启动 S3 兼容的上传接口", cmdS3},
+		{"webdav", "webdav [--addr host:port] [--user name:password] [--proxy] [--index path]", "启动 WebDAV 网络驱动器，写入的文件上传到 DoDo", cmdWebDAV},
+		{"daemon", "daemon [-j jobs] [--retries n] [--socket path]", "启动后台上传服务", cmdDaemon},
+		{"enqueue", "enqueue [--socket path] files...", "将文件加入后台上传队列", cmdEnqueue},
+		{"status", "status [--json] [--retry] [--clear] [--socket path]", "输出后台上传队列的状态", cmdStatus},
+		{"watch", "watch [-r] [--existing] [--log path] [--exec cmd] dir", "监视目录，自动上传新增或修改的文件", cmdWatch},
+		{"sync", "sync [--index path] [--html path] [--index-only] dir", "增量同步目录，只上传新增或修改的文件，并生成索引", cmdSync},
+		{"rpc", "rpc", "通过标准输入输出提供 JSON-RPC 2.0 接口，供编辑器插件调用", cmdRPC},
+		{"fetch", "fetch [-o path] url", "下载文件直链到本地", cmdFetch},
+		{"help", "help", "输出帮助信息", cmdHelp},
+	}
+}
+
+// Run 执行命令行参数对应的子命令，返回退出码。没有参数时进入交互模式。
+func Run(args []string) int {
+	if err := CheckProfile(Profile); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误: GODODO_PROFILE:", err)
+		return ExitUsage
+	}
+	if len(args) == 0 {
+		return Interactive()
+	}
+	if args[0] == "-h" || args[0] == "--help" {
+		return cmdHelp(nil)
+	}
+	for _, command := range Commands {
+		if command.Name == args[0] {
+			return command.Run(args[1:])
+		}
+	}
+	fmt.Fprintln(os.Stderr, "未知命令:", args[0])
+	PrintUsage(os.Stderr)
+	return ExitUsage
+}
+
+// PrintUsage 输出子命令和退出码的帮助信息。
+func PrintUsage(w io.Writer) {
+	fmt.Fprintln(w, "用法: gododo [command] [flags] [args...]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "不带参数运行时进入交互模式。")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "命令:")
+	for _, command := range Commands {
+		fmt.Fprintf(w, "  %-52s %s\n", command.Usage, command.Summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "退出码:")
+	fmt.Fprintln(w, "  0  全部成功")
+	fmt.Fprintln(w, "  1  一般错误")
+	fmt.Fprintln(w, "  2  命令或参数错误")
+	fmt.Fprintln(w, "  3  未登录或登录已失效")
+	fmt.Fprintln(w, "  4  文件不存在，或文件没有上传记录")
+	fmt.Fprintln(w, "  5  至少一个文件上传失败")
+}
+
+// newFlagSet 创建子命令的参数解析器，解析失败时输出子命令用法。
+func newFlagSet(name string) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		for _, command := range Commands {
+			if command.Name == name {
+				fmt.Fprintln(flags.Output(), "用法: gododo", command.Usage)
+			}
+		}
+		flags.PrintDefaults()
+	}
+	return flags
+}
+
+// parseFlags 解析子命令参数，返回非负数时表示应当以该退出码结束。
+func parseFlags(flags *flag.FlagSet, args []string) int {
+	err := flags.Parse(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	} else if err != nil {
+		return ExitUsage
+	}
+	return -1
+}
+
+// requireUserInfo 读取已登录的用户信息，未登录时输出提示。
+func requireUserInfo() (*UserInfo, int) {
+	userInfo, err := LoadUserInfo()
+	if errors.Is(err, ErrNotLoggedIn) {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 未登录或登录已失效，请先运行 gododo login")
+		return nil, ExitAuth
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return nil, ExitError
+	}
+	return userInfo, ExitOK
+}
+
+// Output 命令行输出方式。
+type Output struct {
+	// 只输出直链，错误输出到标准错误。
+	Quiet bool
+	// 每行输出一个 JSON 对象。
+	JSON bool
+	// 输出直链的二维码，只输出直链或输出 JSON 时输出到标准错误。
+	QR bool
+	// 将直链的二维码保存为 PNG。
+	QRPNG bool
+	// 二维码 PNG 的保存目录，为空时保存到数据目录，与清单和上传历史位于同一目录。
+	QRDir string
+	// 直链输出格式或模板，参见 [FormatLink]。不为空时只输出格式化后的内容。
+	Format string
+}
+
+// Result 输出上传结果。
+func (o Output) Result(result *UploadResult) {
+	if o.QRPNG {
+		dir := o.QRDir
+		if dir == "" {
+			dir = DataPath("")
+		}
+		path, err := SaveLinkQR(result, dir)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "⚠️ 二维码保存失败:", err)
+		}
+		result.QRCode = path
+	}
+	if o.QR {
+		w := os.Stdout
+		if o.Quiet || o.JSON {
+			w = os.Stderr
+		}
+		if err := PrintLinkQR(w, result.URL); err != nil {
+			fmt.Fprintln(os.Stderr, "⚠️ 二维码输出失败:", err)
+		}
+	}
+	switch {
+	case o.JSON:
+		data, _ := json.Marshal(result)
+		fmt.Println(string(data))
+	case o.Format != "":
+		link, err := FormatLink(result, o.Format)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return
+		}
+		fmt.Println(link)
+	case o.Quiet:
+		fmt.Println(result.URL)
+	default:
+		fmt.Println("🎉 上传成功:", result.URL)
+	}
+}
+
+// check 检查输出格式，返回非负数时表示应当以该退出码结束。
+func (o Output) check() int {
+	if o.Format == "" {
+		return -1
+	}
+	if _, err := FormatLink(&UploadResult{}, o.Format); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitUsage
+	}
+	return -1
+}
+
+// Error 输出文件 path 的错误。
+func (o Output) Error(path string, err error) {
+	message := err.Error()
+	if os.IsNotExist(err) {
+		message = "文件不存在，请检查路径是否正确。"
+	}
+	if o.JSON {
+		data, _ := json.Marshal(map[string]string{"path": path, "error": message})
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", path, message)
+}
+
+func cmdUpload(args []string) int {
+	flags := newFlagSet("upload")
+	output := Output{}
+	flags.BoolVar(&output.Quiet, "q", false, "只输出直链")
+	flags.BoolVar(&output.Quiet, "quiet", false, "只输出直链")
+	flags.BoolVar(&output.JSON, "json", false, "每行输出一个 JSON 对象")
+	flags.BoolVar(&output.QR, "qr", false, "在终端输出直链的二维码")
+	flags.BoolVar(&output.QRPNG, "qr-png", false, "将直链的二维码保存为 PNG")
+	flags.StringVar(&output.QRDir, "qr-dir", "", "二维码 PNG 的保存目录，默认为清单所在的数据目录")
+	flags.StringVar(&output.Format, "format", "", "输出格式: "+strings.Join(Formats, "、")+"，或 text/template 模板")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if code := output.check(); code >= 0 {
+		return code
+	}
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	code = ExitOK
+	for _, path := range flags.Args() {
+		result, err := UploadFile(path, userInfo)
+		if err != nil {
+			output.Error(path, err)
+			if os.IsNotExist(err) && code == ExitOK {
+				code = ExitNotFound
+			} else if !os.IsNotExist(err) {
+				code = ExitUpload
+			}
+			continue
+		}
+		output.Result(result)
+	}
+	return code
+}
+
+func cmdLogin(args []string) int {
+	flags := newFlagSet("login")
+	cookies := flags.String("cookies", "", "使用 Netscape cookies.txt 或会话 JSON 登录，无需扫码")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	var userInfo *UserInfo
+	var err error
+	if *cookies != "" {
+		userInfo, err = LoginByCookiesFile(*cookies)
+	} else {
+		userInfo, err = LoginByQR(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitAuth
+	}
+	PrintAccount(userInfo)
+	return ExitOK
+}
+
+func cmdLogout(args []string) int {
+	flags := newFlagSet("logout")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	err := os.Remove(UserInfoPath())
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	fmt.Println("已退出登录")
+	return ExitOK
+}
+
+// Account 当前登录的账号信息，Bilibili 信息仅在存在 cookies.txt 时获取。
+type Account struct {
+	DoDo     *dodo.UserProfile `json:"dodo"`
+	Bilibili *biliqr.NavInfo   `json:"bilibili,omitempty"`
+}
+
+// GetAccount 获取当前登录的 DoDo 账号资料，如果存在 cookies.txt，同时获取 Bilibili 账号信息。
+func GetAccount(userInfo *UserInfo) *Account {
+	account := &Account{}
+	profile, err := dodo.GetUserProfile(userInfo.Token, userInfo.UID)
+	if err != nil {
+		// 账号资料接口没有公开文档，失败时只显示 UID。
+		fmt.Fprintln(os.Stderr, "⚠️ DoDo 账号资料获取失败:", err)
+		profile = &dodo.UserProfile{UID: json.Number(userInfo.UID)}
+	}
+	account.DoDo = profile
+	session, err := biliqr.LoadCookiesFile(DataPath(CookiesFile))
+	if err != nil {
+		return account
+	}
+	nav, err := biliqr.GetNavInfo(session)
+	if err == nil && nav.IsLogin {
+		account.Bilibili = nav
+	}
+	return account
+}
+
+func cmdWhoami(args []string) int {
+	flags := newFlagSet("whoami")
+	asJSON := flags.Bool("json", false, "输出 JSON")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	account := GetAccount(userInfo)
+	if *asJSON {
+		data, _ := json.Marshal(account)
+		fmt.Println(string(data))
+		return ExitOK
+	}
+	if account.DoDo.NickName != "" {
+		fmt.Println("DoDo 昵称:", account.DoDo.NickName)
+	}
+	fmt.Println("DoDo UID:", userInfo.UID)
+	if account.DoDo.AvatarURL != "" {
+		fmt.Println("DoDo 头像:", account.DoDo.AvatarURL)
+	}
+	if nav := account.Bilibili; nav != nil {
+		fmt.Println("Bilibili 昵称:", nav.Uname)
+		fmt.Println("Bilibili mid:", nav.Mid)
+		fmt.Println("Bilibili 头像:", nav.Face)
+		if nav.IsVip() {
+			fmt.Println("Bilibili 大会员:", nav.VipLabel.Text)
+		}
+	}
+	return ExitOK
+}
+
+func cmdHistory(args []string) int {
+	flags := newFlagSet("history")
+	count := flags.Int("n", 20, "最多输出的记录数，0 表示全部")
+	output := Output{}
+	flags.BoolVar(&output.JSON, "json", false, "每行输出一个 JSON 对象")
+	flags.StringVar(&output.Format, "format", "", "输出格式: "+strings.Join(Formats, "、")+"，或 text/template 模板")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if code := output.check(); code >= 0 {
+		return code
+	}
+	if flags.NArg() > 0 {
+		return checkHistory(flags.Args(), output)
+	}
+	results, err := ReadHistory()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	if *count > 0 && len(results) > *count {
+		results = results[len(results)-*count:]
+	}
+	for _, result := range results {
+		if output.JSON || output.Format != "" {
+			output.Result(result)
+			continue
+		}
+		fmt.Printf("%s  %s  %s\n", result.Time.Format("2006-01-02 15:04:05"), result.Base, result.URL)
+	}
+	return ExitOK
+}
+
+// checkHistory 查询文件是否已上传，不会上传文件。
+func checkHistory(paths []string, output Output) int {
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	code = ExitOK
+	for _, path := range paths {
+		work, err := dodo.NewUploadWork(path, userInfo.Token, userInfo.UID)
+		if err != nil {
+			output.Error(path, err)
+			code = ExitNotFound
+			continue
+		}
+		history, err := work.History()
+		if err != nil {
+			output.Error(path, err)
+			code = ExitError
+			continue
+		}
+		if !history.HasRecord {
+			output.Error(path, errors.New("没有上传记录"))
+			code = ExitNotFound
+			continue
+		}
+		output.Result(NewUploadResult(work, history.ResourceURL, true))
+	}
+	return code
+}
+
+func cmdFetch(args []string) int {
+	flags := newFlagSet("fetch")
+	out := flags.String("o", "", "保存路径，默认为直链中的文件名")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if err := Fetch(flags.Arg(0), *out); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+// Fetch 下载直链到 out，out 为空时使用直链中的文件名。
+func Fetch(resourceURL string, out string) error {
+	if out == "" {
+		u, err := url.Parse(resourceURL)
+		if err != nil {
+			return err
+		}
+		out = path.Base(u.Path)
+		if out == "/" || out == "." {
+			return errors.New("无法从直链中获取文件名，请使用 -o 指定保存路径")
+		}
+	}
+	response, err := http.Get(resourceURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return errors.New(response.Status)
+	}
+	// 先写入同一目录中的临时文件，下载失败时不留下不完整的文件，也不覆盖已有的文件。
+	file, err := os.CreateTemp(filepath.Dir(out), "."+filepath.Base(out)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(file, response.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), out)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
+
+func cmdHelp(args []string) int {
+	PrintUsage(os.Stdout)
+	return ExitOK
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// SocketFile 后台上传服务的控制套接字文件名，位于 [DataPath] 指定的目录。
+const SocketFile = "gododo.sock"
+
+// Daemon 后台上传服务，依次上传队列中的文件，并通过 Unix 套接字接收 enqueue 和 status 命令。
+//
+// 上传失败的任务按 30 秒、1 分钟、2 分钟……递增的间隔重试，最长间隔 30 分钟，
+// 超过 Retries 次后标记为失败。文件不存在时不再重试。
+type Daemon struct {
+	Queue *Queue
+	// 同时上传的文件数。
+	Jobs int
+	// 每个任务最多尝试的次数。
+	Retries int
+	// 上传文件，上传成功后应当追加上传历史。
+	Upload func(ctx context.Context, path string, progress func(sent int64, total int64)) (*UploadResult, error)
+	wake   chan struct{}
+}
+
+// daemonRequest 控制套接字的请求，每个连接一个请求和一个响应，均为单行 JSON。
+type daemonRequest struct {
+	Command string   `json:"command"`
+	Paths   []string `json:"paths,omitempty"`
+}
+
+type daemonResponse struct {
+	Jobs  []*Job `json:"jobs,omitempty"`
+	Count int    `json:"count,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// Run 处理 listener 上的连接并上传队列中的文件，ctx 取消后关闭 listener，等待正在上传的任务停止后返回。
+//
+// 被中断的任务保持正在上传的状态，下次读取队列时重新排队。
+func (d *Daemon) Run(ctx context.Context, listener net.Listener) error {
+	d.wake = make(chan struct{}, 1)
+	jobs := max(d.Jobs, 1)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.work(ctx)
+		}()
+	}
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+	var err error
+	for {
+		var conn net.Conn
+		conn, err = listener.Accept()
+		if err != nil {
+			break
+		}
+		go d.handle(conn)
+	}
+	wg.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
+
+// signal 唤醒一个等待中的上传协程。
+func (d *Daemon) signal() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (d *Daemon) work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := d.Queue.Next(time.Now())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "⚠️ 队列保存失败:", err)
+		}
+		if job == nil {
+			d.wait(ctx)
+			continue
+		}
+		// 队列中可能还有其他任务，唤醒下一个协程。
+		d.signal()
+		result, err := d.Upload(ctx, job.Path, func(sent int64, total int64) {
+			d.Queue.Progress(job.ID, sent, total)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+		retryAt := time.Time{}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❗ #%d %s: 第 %d 次上传失败: %s\n", job.ID, job.Path, job.Attempts, err)
+			if job.Attempts < d.Retries && !os.IsNotExist(err) {
+				retryAt = time.Now().Add(retryDelay(job.Attempts))
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "#%d %s -> %s\n", job.ID, job.Path, result.URL)
+		}
+		if err = d.Queue.Finish(job.ID, result, err, retryAt); err != nil {
+			fmt.Fprintln(os.Stderr, "⚠️ 队列保存失败:", err)
+		}
+	}
+}
+
+// wait 等待新任务或最早的重试时间。
+func (d *Daemon) wait(ctx context.Context) {
+	var timeout <-chan time.Time
+	if next, ok := d.Queue.NextTry(); ok {
+		timer := time.NewTimer(time.Until(next))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	select {
+	case <-ctx.Done():
+	case <-d.wake:
+	case <-timeout:
+	}
+}
+
+// retryDelay 返回第 attempts 次失败后的重试间隔。
+func retryDelay(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < 30*time.Minute; i++ {
+		delay *= 2
+	}
+	return min(delay, 30*time.Minute)
+}
+
+func (d *Daemon) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	var request daemonRequest
+	response := &daemonResponse{}
+	var err error
+	if err = json.NewDecoder(conn).Decode(&request); err != nil {
+		response.Error = err.Error()
+	} else if response, err = d.exec(request); err != nil {
+		response = &daemonResponse{Error: err.Error()}
+	}
+	json.NewEncoder(conn).Encode(response)
+}
+
+func (d *Daemon) exec(request daemonRequest) (*daemonResponse, error) {
+	switch request.Command {
+	case "enqueue":
+		jobs, err := d.Queue.Add(request.Paths)
+		if err != nil {
+			return nil, err
+		}
+		d.signal()
+		return &daemonResponse{Jobs: jobs}, nil
+	case "status":
+		return &daemonResponse{Jobs: d.Queue.Snapshot()}, nil
+	case "retry":
+		count, err := d.Queue.Retry()
+		d.signal()
+		return &daemonResponse{Count: count}, err
+	case "clear":
+		count, err := d.Queue.Clear()
+		return &daemonResponse{Count: count}, err
+	}
+	return nil, fmt.Errorf("未知命令: %s", request.Command)
+}
+
+// ErrDaemonNotRunning 无法连接后台上传服务。
+var ErrDaemonNotRunning = errors.New("后台上传服务未运行，请先运行 gododo daemon")
+
+// callDaemon 向后台上传服务发送请求并返回响应。
+func callDaemon(socket string, request daemonRequest) (*daemonResponse, error) {
+	conn, err := net.DialTimeout("unix", socket, 2*time.Second)
+	if err != nil {
+		return nil, ErrDaemonNotRunning
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err = json.NewEncoder(conn).Encode(request); err != nil {
+		return nil, err
+	}
+	response := &daemonResponse{}
+	if err = json.NewDecoder(conn).Decode(response); err != nil {
+		return nil, err
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+	return response, nil
+}
+
+// listenSocket 监听控制套接字，删除上次异常退出时留下的套接字文件。
+func listenSocket(socket string) (net.Listener, error) {
+	if conn, err := net.DialTimeout("unix", socket, time.Second); err == nil {
+		conn.Close()
+		return nil, errors.New("后台上传服务已在运行: " + socket)
+	}
+	os.Remove(socket)
+	if err := os.MkdirAll(filepath.Dir(socket), 0755); err != nil {
+		return nil, err
+	}
+	// 任何能连接套接字的用户都可以使用当前账号上传文件，因此创建时就限制权限，而不是创建后再修改。
+	listener, err := listenUnix(socket)
+	if err != nil {
+		return nil, err
+	}
+	if err = os.Chmod(socket, 0600); err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func cmdDaemon(args []string) int {
+	flags := newFlagSet("daemon")
+	socket := flags.String("socket", DataPath(SocketFile), "控制套接字路径")
+	jobs := flags.Int("j", 2, "同时上传的文件数")
+	retries := flags.Int("retries", 5, "每个文件最多尝试的次数")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 0 || *jobs < 1 || *retries < 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	queue, err := LoadQueue(DataPath(QueueFile))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	listener, err := listenSocket(*socket)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	defer os.Remove(*socket)
+	daemon := &Daemon{
+		Queue:   queue,
+		Jobs:    *jobs,
+		Retries: *retries,
+		Upload: func(ctx context.Context, path string, progress func(sent int64, total int64)) (*UploadResult, error) {
+			return UploadFileContext(ctx, path, userInfo, progress)
+		},
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "👂 后台上传服务已启动: %s\n", *socket)
+	if err = daemon.Run(ctx, listener); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	return ExitOK
+}
+
+func cmdEnqueue(args []string) int {
+	flags := newFlagSet("enqueue")
+	socket := flags.String("socket", DataPath(SocketFile), "控制套接字路径")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	paths := []string{}
+	code := ExitOK
+	for _, path := range flags.Args() {
+		stat, err := os.Stat(path)
+		if err == nil && stat.IsDir() {
+			err = errors.New("不能上传目录")
+		}
+		if err == nil {
+			// 后台服务的工作目录可能不同，使用绝对路径。
+			path, err = filepath.Abs(path)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", path, err)
+			code = ExitNotFound
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(paths) == 0 {
+		return code
+	}
+	response, err := callDaemon(*socket, daemonRequest{Command: "enqueue", Paths: paths})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	for _, job := range response.Jobs {
+		fmt.Printf("#%d %s\n", job.ID, job.Path)
+	}
+	return code
+}
+
+func cmdStatus(args []string) int {
+	flags := newFlagSet("status")
+	socket := flags.String("socket", DataPath(SocketFile), "控制套接字路径")
+	asJSON := flags.Bool("json", false, "每行输出一个 JSON 对象")
+	retry := flags.Bool("retry", false, "重新上传失败的文件")
+	clear := flags.Bool("clear", false, "删除已完成的任务")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if *retry {
+		response, err := callDaemon(*socket, daemonRequest{Command: "retry"})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+		fmt.Fprintf(os.Stderr, "已重新排队 %d 个文件\n", response.Count)
+	}
+	if *clear {
+		response, err := callDaemon(*socket, daemonRequest{Command: "clear"})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+		fmt.Fprintf(os.Stderr, "已删除 %d 个已完成的任务\n", response.Count)
+	}
+	response, err := callDaemon(*socket, daemonRequest{Command: "status"})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	for _, job := range response.Jobs {
+		if *asJSON {
+			data, _ := json.Marshal(job)
+			fmt.Println(string(data))
+			continue
+		}
+		fmt.Printf("#%-4d %-9s %s  %s\n", job.ID, job.State, job.Path, jobDetail(job))
+	}
+	return ExitOK
+}
+
+// jobDetail 返回任务状态的说明。
+func jobDetail(job *Job) string {
+	switch job.State {
+	case JobUploading:
+		if job.Total == 0 {
+			return "正在计算 MD5"
+		}
+		return fmt.Sprintf("%d%% %s/%s", job.Sent*100/job.Total, HumanSize(job.Sent), HumanSize(job.Total))
+	case JobDone:
+		return job.Result.URL
+	case JobFailed:
+		return fmt.Sprintf("第 %d 次上传失败: %s", job.Attempts, job.Error)
+	}
+	if job.Error != "" {
+		return fmt.Sprintf("将于 %s 重试，第 %d 次上传失败: %s", job.NextTry.Format("15:04:05"), job.Attempts, job.Error)
+	}
+	return "等待上传"
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDaemon(t *testing.T) {
+	dir := t.TempDir()
+	queuePath := filepath.Join(dir, QueueFile)
+	queue, err := LoadQueue(queuePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := listenSocket(filepath.Join(dir, SocketFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := os.Stat(filepath.Join(dir, SocketFile)); err != nil || stat.Mode().Perm()&0077 != 0 {
+		t.Errorf("socket mode = %v, %v", stat.Mode(), err)
+	}
+	daemon := &Daemon{
+		Queue:   queue,
+		Jobs:    2,
+		Retries: 3,
+		Upload: func(ctx context.Context, path string, progress func(sent int64, total int64)) (*UploadResult, error) {
+			if path == "/missing" {
+				return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+			}
+			progress(1, 1)
+			return &UploadResult{Path: path, URL: "https://files.imdodo.com/dodo/" + filepath.Base(path)}, nil
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- daemon.Run(ctx, listener) }()
+
+	socket := filepath.Join(dir, SocketFile)
+	if _, err = listenSocket(socket); err == nil {
+		t.Error("listenSocket succeeded while the daemon is running")
+	}
+	response, err := callDaemon(socket, daemonRequest{Command: "enqueue", Paths: []string{"/a.png", "/missing", "/b.png"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Jobs) != 3 || response.Jobs[2].ID != 3 {
+		t.Fatalf("enqueue = %+v", response.Jobs)
+	}
+	want := map[string]string{"/a.png": JobDone, "/missing": JobFailed, "/b.png": JobDone}
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		response, err = callDaemon(socket, daemonRequest{Command: "status"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		finished := 0
+		for _, job := range response.Jobs {
+			if job.State == want[job.Path] {
+				finished++
+			}
+		}
+		if finished == len(want) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v", response.Jobs)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err = callDaemon(socket, daemonRequest{Command: "nope"}); err == nil {
+		t.Error("unknown command succeeded")
+	}
+	response, err = callDaemon(socket, daemonRequest{Command: "clear"})
+	if err != nil || response.Count != 2 {
+		t.Errorf("clear = %+v, %v", response, err)
+	}
+	cancel()
+	if err = <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	queue, err = LoadQueue(queuePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue.Jobs) != 1 || queue.Jobs[0].Path != "/missing" || queue.Jobs[0].Attempts != 1 || queue.NextID != 4 {
+		t.Errorf("saved queue = %+v", queue.Jobs)
+	}
+	if count, _ := queue.Retry(); count != 1 {
+		t.Errorf("Retry() = %d, want 1", count)
+	}
+}
+
+func TestLoadQueueRequeue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), QueueFile)
+	queue, _ := LoadQueue(path)
+	queue.Add([]string{"/a.png"})
+	if job, err := queue.Next(time.Now()); err != nil || job.State != JobUploading {
+		t.Fatalf("Next() = %+v, %v", job, err)
+	}
+	queue, err := LoadQueue(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := queue.Jobs[0]; job.State != JobPending || job.Attempts != 1 {
+		t.Errorf("reloaded job = %+v", job)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 3: 2 * time.Minute, 20: 30 * time.Minute} {
+		if got := retryDelay(attempts); got != want {
+			t.Errorf("retryDelay(%d) = %s, want %s", attempts, got, want)
+		}
+	}
+}
+
+func TestQueueSaveProgress(t *testing.T) {
+	path := filepath.Join(t.TempDir(), QueueFile)
+	queue, _ := LoadQueue(path)
+	jobs, _ := queue.Add([]string{"/a.png"})
+	queue.Progress(jobs[0].ID, 1, 2)
+	if _, err := queue.Add([]string{"/b.png"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), `"sent"`) {
+		t.Errorf("progress saved to the queue file: %s", data)
+	}
+	if job := queue.Snapshot()[0]; job.Sent != 1 || job.Total != 2 {
+		t.Errorf("progress lost in memory: %+v", job)
+	}
+}
@@ -22,6 +22,7 @@ package dodo
 
 import (
 	"bytes"
+	"context"
 	"crypto/hmac"
 	"crypto/md5"
 	"crypto/sha1"
@@ -50,16 +51,35 @@ import (
 //
 // tmpToken 在扫码确认后由 [biliqr.GetThirdQRStatus] 返回。
 func GetTokenAndUID(tmpToken string) (token string, uid string, err error) {
-	// clientId 和 returnURL 可以在三方网站跳转到 B 站授权页面时携带的 GET 参数获得。
-	clientId := "0c95e37758534eb7"
-	returnURL := "https://www.imdodo.com/thirdLogin/biliLogin"
 	codeInfo, err := biliqr.GetAuthorizeCode(clientId, tmpToken, returnURL)
 	if err != nil {
 		return "", "", err
 	}
+	return GetTokenAndUIDByCode(codeInfo.Data.Code)
+}
+
+// GetTokenAndUIDBySession 使用已登录的 Bilibili 官网会话获取 Token 和 UID，无需扫码。
+//
+// session 可由 [biliqr.GetQRStatus] 返回，或由 [biliqr.LoadCookiesFile] 从 Cookie 文件读取。
+func GetTokenAndUIDBySession(session *biliqr.Session) (token string, uid string, err error) {
+	codeInfo, err := biliqr.GetAuthorizeCodeBySession(session, clientId, returnURL)
+	if err != nil {
+		return "", "", err
+	}
+	return GetTokenAndUIDByCode(codeInfo.Data.Code)
+}
+
+// clientId 和 returnURL 可以在三方网站跳转到 B 站授权页面时携带的 GET 参数获得。
+const (
+	clientId  = "0c95e37758534eb7"
+	returnURL = "https://www.imdodo.com/thirdLogin/biliLogin"
+)
+
+// GetTokenAndUIDByCode 使用 Bilibili 授权后返回的 Code 获取 Token 和 UID。
+func GetTokenAndUIDByCode(code string) (token string, uid string, err error) {
 	apiKey, sha1Key := RandKeyConfig()
 	body := url.Values{
-		"code":   {codeInfo.Data.Code},
+		"code":   {code},
 		"apikey": {apiKey},
 	}
 	sig := HmacSha1Encrypt([]byte(sha1Key), []byte(body.Encode()))
@@ -156,12 +176,65 @@ type UploadWork struct {
 	Base string
 	Ext  string
 	MD5  string
+	// 不为空时，在上传过程中报告已发送的文件字节数和文件总大小。
+	Progress func(sent int64, total int64)
+}
+
+// ProgressReader 在读取时报告已读取的字节数和总大小。
+type ProgressReader struct {
+	Reader   io.Reader
+	Total    int64
+	Progress func(sent int64, total int64)
+	sent     int64
+}
+
+func (r *ProgressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.sent += int64(n)
+		r.Progress(r.sent, r.Total)
+	}
+	return n, err
+}
+
+// ResourceURL 返回文件直链，需要在上传并提交记录后才能访问。
+func (w UploadWork) ResourceURL() string {
+	return "https://files.imdodo.com/dodo/" + w.MD5 + w.Ext
+}
+
+// Publish 获取历史上传记录，如果不存在则上传文件并提交记录，返回文件直链。
+//
+// cached 为 true 表示文件已经被上传过，本次没有重复上传。
+func (w UploadWork) Publish() (resourceURL string, cached bool, err error) {
+	return w.PublishContext(context.Background())
+}
+
+// PublishContext 与 [UploadWork.Publish] 相同，ctx 取消后停止上传。
+func (w UploadWork) PublishContext(ctx context.Context) (resourceURL string, cached bool, err error) {
+	history, err := w.History()
+	if err != nil {
+		return "", false, err
+	}
+	if history.HasRecord {
+		return history.ResourceURL, true, nil
+	}
+	if err = ctx.Err(); err != nil {
+		return "", false, err
+	}
+	if err = w.UploadContext(ctx); err != nil {
+		return "", false, err
+	}
+	resourceURL, err = w.Record()
+	if err != nil {
+		return "", false, err
+	}
+	return resourceURL, false, nil
 }
 
 // 提交文件上传记录，使文件直链生效。
 func (w UploadWork) Record() (string, error) {
 	apiKey, sha1Key := RandKeyConfig()
-	resourceUrl := "https://files.imdodo.com/dodo/" + w.MD5 + w.Ext
+	resourceUrl := w.ResourceURL()
 	params, body := ParseParamArray([][2]string{
 		{"MD5Str", w.MD5},
 		{"apikey", apiKey},
@@ -214,10 +287,15 @@ func (w UploadWork) Record() (string, error) {
 	return resourceUrl, nil
 }
 
+// Upload 上传文件，文件内容直接从磁盘读取并发送，不会整个读入内存。
 func (w UploadWork) Upload() error {
-	var b bytes.Buffer
-	writer := multipart.NewWriter(&b)
-	defer writer.Close()
+	return w.UploadContext(context.Background())
+}
+
+// UploadContext 与 [UploadWork.Upload] 相同，ctx 取消后停止上传。
+func (w UploadWork) UploadContext(ctx context.Context) error {
+	var head bytes.Buffer
+	writer := multipart.NewWriter(&head)
 	config, err := w.Config()
 	if err != nil {
 		return err
@@ -239,7 +317,7 @@ func (w UploadWork) Upload() error {
 	if err != nil {
 		return err
 	}
-	part, err := writer.CreateFormFile("file", w.Base)
+	_, err = writer.CreateFormFile("file", w.Base)
 	if err != nil {
 		return err
 	}
@@ -248,15 +326,22 @@ func (w UploadWork) Upload() error {
 		return err
 	}
 	defer file.Close()
-	_, err = io.Copy(part, file)
+	stat, err := file.Stat()
 	if err != nil {
 		return err
 	}
-	writer.Close()
-	request, err := http.NewRequest("POST", config.Host, &b)
+	// 与 writer.Close 写入的结束边界相同。
+	tail := "\r\n--" + writer.Boundary() + "--\r\n"
+	var content io.Reader = file
+	if w.Progress != nil {
+		content = &ProgressReader{Reader: file, Total: stat.Size(), Progress: w.Progress}
+	}
+	body := io.MultiReader(&head, content, strings.NewReader(tail))
+	request, err := http.NewRequestWithContext(ctx, "POST", config.Host, body)
 	if err != nil {
 		return err
 	}
+	request.ContentLength = int64(head.Len()) + stat.Size() + int64(len(tail))
 	request.Header.Set("Content-Type", writer.FormDataContentType())
 	client := http.Client{
 		Transport: &http.Transport{
@@ -3,6 +3,7 @@ package dodo_test
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 	"time"
@@ -45,7 +46,7 @@ func TestGetTokenAndUID(t *testing.T) {
 }
 
 func TestUpload(t *testing.T) {
-	path := "test-file-123"
+	path := filepath.Join(t.TempDir(), "test-file-123")
 	err := os.WriteFile(path, []byte(strconv.FormatInt(time.Now().UnixNano(), 10)), os.ModePerm)
 	if err != nil {
 		t.Fatal(err)
@@ -0,0 +1,61 @@
+package dodo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/iuroc/gododo/biliqr"
+)
+
+// UserProfile DoDo 账号资料。
+type UserProfile struct {
+	UID       json.Number `json:"uid"`
+	NickName  string      `json:"nickName"`
+	AvatarURL string      `json:"avatarUrl"`
+}
+
+// UserInfoURL 获取账号资料的接口。
+//
+// 该接口没有公开文档，请求签名方式与上传记录等接口相同，响应格式可能随 DoDo 客户端更新而变化，
+// 调用方应当在失败时降级为只显示 UID。
+var UserInfoURL = "https://apis.imdodo.com/api/user/info"
+
+// GetUserProfile 获取 Token 和 UID 对应的 DoDo 账号资料。
+//
+// token 和 uid: [GetTokenAndUID] 获取得到。
+func GetUserProfile(token string, uid string) (*UserProfile, error) {
+	apiKey, sha1Key := RandKeyConfig()
+	body := url.Values{
+		"apikey":        {apiKey},
+		"clientType":    {"3"},
+		"clientVersion": {"0.14.2"},
+		"timestamp":     {strconv.FormatInt(time.Now().Unix(), 10)},
+		"token":         {token},
+		"uid":           {uid},
+	}
+	sig := HmacSha1Encrypt([]byte(sha1Key), []byte(body.Encode()))
+	body.Set("sig", sig)
+	data, err := biliqr.SimplePost(UserInfoURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	var profile struct {
+		Data    UserProfile `json:"data"`
+		Message string      `json:"message"`
+		Status  int         `json:"status"`
+	}
+	err = json.Unmarshal(data, &profile)
+	if err != nil {
+		return nil, err
+	}
+	if profile.Status != 0 {
+		return nil, errors.New(profile.Message)
+	}
+	if profile.Data.UID == "" {
+		profile.Data.UID = json.Number(uid)
+	}
+	return &profile.Data, nil
+}
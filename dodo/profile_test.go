@@ -0,0 +1,39 @@
+package dodo_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iuroc/gododo/dodo"
+)
+
+func TestGetUserProfile(t *testing.T) {
+	responses := map[string]string{
+		"1": `{"status":0,"message":"","data":{"uid":1,"nickName":"dodo","avatarUrl":"https://img.imdodo.com/a.png"}}`,
+		"2": `{"status":0,"data":{}}`,
+		"3": `{"status":-1,"message":"token 已失效"}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("token") != "token" || r.PostForm.Get("sig") == "" {
+			t.Errorf("form = %v", r.PostForm)
+		}
+		w.Write([]byte(responses[r.PostForm.Get("uid")]))
+	}))
+	defer server.Close()
+	defer func(url string) { dodo.UserInfoURL = url }(dodo.UserInfoURL)
+	dodo.UserInfoURL = server.URL
+
+	profile, err := dodo.GetUserProfile("token", "1")
+	if err != nil || profile.UID != "1" || profile.NickName != "dodo" || profile.AvatarURL != "https://img.imdodo.com/a.png" {
+		t.Errorf("GetUserProfile(1) = %+v, %v", profile, err)
+	}
+	profile, err = dodo.GetUserProfile("token", "2")
+	if err != nil || profile.UID != "2" || profile.NickName != "" {
+		t.Errorf("GetUserProfile(2) = %+v, %v", profile, err)
+	}
+	if _, err = dodo.GetUserProfile("token", "3"); err == nil || err.Error() != "token 已失效" {
+		t.Errorf("GetUserProfile(3) error = %v", err)
+	}
+}
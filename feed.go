@@ -0,0 +1,389 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FeedConfig 播客的元数据，通常从 JSON 配置文件读取。
+type FeedConfig struct {
+	Title       string `json:"title"`
+	Link        string `json:"link"`
+	Description string `json:"description"`
+	Language    string `json:"language"`
+	Author      string `json:"author"`
+	// 封面图片的直链或本地路径，本地文件会被上传。
+	Image    string `json:"image"`
+	Category string `json:"category"`
+	Explicit bool   `json:"explicit"`
+	// 节目列表，命令行中的文件会追加到列表末尾。
+	Episodes []*FeedEpisode `json:"episodes"`
+}
+
+// FeedEpisode 一期节目。File 为本地文件时会被上传，已上传的节目可以直接填写 URL、Size、MD5 和 Type。
+type FeedEpisode struct {
+	File        string `json:"file,omitempty"`
+	URL         string `json:"url,omitempty"`
+	Size        int64  `json:"size,omitempty"`
+	MD5         string `json:"md5,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	// 发布时间，支持 RFC 3339、2006-01-02 15:04 和 2006-01-02，默认为文件的修改时间。
+	Date string `json:"date,omitempty"`
+	// 时长，例如 1:02:03 或秒数。
+	Duration string `json:"duration,omitempty"`
+	pubDate  time.Time
+}
+
+// LoadFeedConfig 读取 JSON 配置文件，节目的相对路径相对于配置文件所在的目录。
+func LoadFeedConfig(path string) (*FeedConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	config := &FeedConfig{}
+	if err = json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	baseDir := filepath.Dir(path)
+	if config.Image != "" && !urlScheme.MatchString(config.Image) && !filepath.IsAbs(config.Image) {
+		config.Image = filepath.Join(baseDir, config.Image)
+	}
+	for _, episode := range config.Episodes {
+		if episode.File != "" && !filepath.IsAbs(episode.File) {
+			episode.File = filepath.Join(baseDir, episode.File)
+		}
+	}
+	return config, nil
+}
+
+// Resolve 上传封面和节目文件，补全节目的直链、大小、MD5、类型、标题和发布时间，返回上传失败的文件数量。
+func (config *FeedConfig) Resolve(uploader *Uploader) (failed int) {
+	if config.Image != "" && !urlScheme.MatchString(config.Image) {
+		result, err := uploader.Upload(config.Image)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", config.Image, err)
+			failed++
+		} else {
+			config.Image = result.URL
+		}
+	}
+	for _, episode := range config.Episodes {
+		if err := episode.resolve(uploader); err != nil {
+			fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", episode.File, err)
+			failed++
+		}
+	}
+	return failed
+}
+
+func (episode *FeedEpisode) resolve(uploader *Uploader) error {
+	var modTime time.Time
+	if episode.File != "" {
+		result, err := uploader.Upload(episode.File)
+		if err != nil {
+			return err
+		}
+		episode.URL, episode.Size, episode.MD5 = result.URL, result.Size, result.MD5
+		if stat, err := os.Stat(episode.File); err == nil {
+			modTime = stat.ModTime()
+		}
+		if episode.Title == "" {
+			episode.Title = strings.TrimSuffix(result.Base, result.Ext)
+		}
+	}
+	if episode.URL == "" {
+		return fmt.Errorf("节目 %q 缺少文件或直链", episode.Title)
+	}
+	if episode.Type == "" {
+		episode.Type = MediaType(filepath.Ext(episode.URL))
+	}
+	if episode.Title == "" {
+		episode.Title = strings.TrimSuffix(filepath.Base(episode.URL), filepath.Ext(episode.URL))
+	}
+	episode.pubDate = modTime
+	if episode.Date != "" {
+		date, err := parseFeedDate(episode.Date)
+		if err != nil {
+			return err
+		}
+		episode.pubDate = date
+	}
+	if episode.pubDate.IsZero() {
+		episode.pubDate = time.Now()
+	}
+	return nil
+}
+
+func parseFeedDate(text string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04", "2006-01-02"} {
+		if date, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法解析发布时间: %s", text)
+}
+
+// Seconds 返回节目时长的秒数，无法解析时返回 -1。
+func (episode *FeedEpisode) Seconds() int {
+	if episode.Duration == "" {
+		return -1
+	}
+	seconds := 0
+	for _, part := range strings.Split(episode.Duration, ":") {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return -1
+		}
+		seconds = seconds*60 + value
+	}
+	return seconds
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	ITunes  string     `xml:"xmlns:itunes,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title          string      `xml:"title"`
+	Link           string      `xml:"link"`
+	Description    string      `xml:"description"`
+	Language       string      `xml:"language,omitempty"`
+	LastBuildDate  string      `xml:"lastBuildDate"`
+	Generator      string      `xml:"generator"`
+	ITunesAuthor   string      `xml:"itunes:author,omitempty"`
+	ITunesImage    *itunesHref `xml:"itunes:image,omitempty"`
+	ITunesCategory *itunesText `xml:"itunes:category,omitempty"`
+	ITunesExplicit string      `xml:"itunes:explicit"`
+	Image          *rssImage   `xml:"image,omitempty"`
+	Items          []rssItem   `xml:"item"`
+}
+
+type itunesHref struct {
+	Href string `xml:"href,attr"`
+}
+
+type itunesText struct {
+	Text string `xml:"text,attr"`
+}
+
+type rssImage struct {
+	URL   string `xml:"url"`
+	Title string `xml:"title"`
+	Link  string `xml:"link"`
+}
+
+type rssItem struct {
+	Title          string       `xml:"title"`
+	Description    string       `xml:"description,omitempty"`
+	PubDate        string       `xml:"pubDate"`
+	GUID           rssGUID      `xml:"guid"`
+	Enclosure      rssEnclosure `xml:"enclosure"`
+	ITunesDuration string       `xml:"itunes:duration,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length int64  `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+// WriteRSS 输出 RSS 2.0 播客订阅，节目按发布时间从新到旧排列。需要先调用 [FeedConfig.Resolve]。
+func (config *FeedConfig) WriteRSS(w io.Writer) error {
+	link := config.Link
+	if link == "" {
+		link = "https://github.com/iuroc/gododo"
+	}
+	channel := rssChannel{
+		Title:          config.Title,
+		Link:           link,
+		Description:    config.Description,
+		Language:       config.Language,
+		LastBuildDate:  time.Now().Format(time.RFC1123Z),
+		Generator:      "gododo",
+		ITunesAuthor:   config.Author,
+		ITunesExplicit: strconv.FormatBool(config.Explicit),
+	}
+	if config.Image != "" {
+		channel.ITunesImage = &itunesHref{config.Image}
+		channel.Image = &rssImage{URL: config.Image, Title: config.Title, Link: link}
+	}
+	if config.Category != "" {
+		channel.ITunesCategory = &itunesText{config.Category}
+	}
+	episodes := append([]*FeedEpisode{}, config.Episodes...)
+	sort.SliceStable(episodes, func(i, j int) bool {
+		return episodes[i].pubDate.After(episodes[j].pubDate)
+	})
+	for _, episode := range episodes {
+		guid := rssGUID{Value: episode.MD5}
+		if guid.Value == "" {
+			guid = rssGUID{IsPermaLink: true, Value: episode.URL}
+		}
+		channel.Items = append(channel.Items, rssItem{
+			Title:          episode.Title,
+			Description:    episode.Description,
+			PubDate:        episode.pubDate.Format(time.RFC1123Z),
+			GUID:           guid,
+			Enclosure:      rssEnclosure{URL: episode.URL, Length: episode.Size, Type: episode.Type},
+			ITunesDuration: episode.Duration,
+		})
+	}
+	feed := rssFeed{
+		Version: "2.0",
+		ITunes:  "http://www.itunes.com/dtds/podcast-1.0.dtd",
+		Channel: channel,
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteM3U 按配置中的顺序输出扩展 M3U 播放列表，使用 UTF-8 编码，可以保存为 .m3u 或 .m3u8。
+func (config *FeedConfig) WriteM3U(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "#EXTM3U"); err != nil {
+		return err
+	}
+	if config.Title != "" {
+		fmt.Fprintf(w, "#PLAYLIST:%s\n", config.Title)
+	}
+	for _, episode := range config.Episodes {
+		if _, err := fmt.Fprintf(w, "#EXTINF:%d,%s\n%s\n", episode.Seconds(), episode.Title, episode.URL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFeedFile 将生成的内容写入 path，path 为 - 时输出到标准输出。
+func writeFeedFile(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func cmdFeed(args []string) int {
+	flags := newFlagSet("feed")
+	configPath := flags.String("c", "", "播客配置文件，JSON 格式")
+	rssPath := flags.String("o", "", "RSS 输出路径，默认输出到标准输出，- 表示标准输出")
+	m3uPath := flags.String("m3u", "", "M3U 播放列表输出路径")
+	upload := flags.Bool("upload", false, "上传生成的 RSS 和播放列表并输出直链")
+	manifestPath := flags.String("manifest", DataPath("manifest.json"), "清单文件，未变化的文件不会重新上传")
+	dryRun := flags.Bool("dry-run", false, "不上传文件，只计算直链")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	config := &FeedConfig{}
+	if *configPath != "" {
+		var err error
+		if config, err = LoadFeedConfig(*configPath); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+	}
+	for _, path := range flags.Args() {
+		config.Episodes = append(config.Episodes, &FeedEpisode{File: path})
+	}
+	if len(config.Episodes) == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if config.Title == "" {
+		config.Title = "gododo"
+	}
+	manifest, err := LoadManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	uploader := &Uploader{DryRun: *dryRun, Manifest: manifest}
+	if !*dryRun {
+		userInfo, code := requireUserInfo()
+		if userInfo == nil {
+			return code
+		}
+		uploader.UserInfo = userInfo
+	}
+	failed := config.Resolve(uploader)
+	if !*dryRun {
+		if err := manifest.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		}
+	}
+	if failed > 0 {
+		return ExitUpload
+	}
+	var tempDir string
+	if *upload {
+		if tempDir, err = os.MkdirTemp("", "gododo-feed-"); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+		defer os.RemoveAll(tempDir)
+	}
+	if *rssPath == "" && *upload {
+		*rssPath = filepath.Join(tempDir, "feed.xml")
+	} else if *rssPath == "" {
+		*rssPath = "-"
+	}
+	outputs := []struct {
+		path  string
+		write func(w io.Writer) error
+	}{
+		{*rssPath, config.WriteRSS},
+		{*m3uPath, config.WriteM3U},
+	}
+	for _, output := range outputs {
+		if output.path == "" {
+			continue
+		}
+		if err := writeFeedFile(output.path, output.write); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+		if !*upload || output.path == "-" {
+			continue
+		}
+		// 生成的文件每次内容不同，不记录到清单。
+		result, err := (&Uploader{UserInfo: uploader.UserInfo, DryRun: *dryRun}).Upload(output.path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitUpload
+		}
+		fmt.Println(result.URL)
+	}
+	return ExitOK
+}
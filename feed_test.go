@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteFeed(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ep1.mp3"), []byte("episode 1"), 0644)
+	os.WriteFile(filepath.Join(dir, "feed.json"), []byte(`{
+		"title": "测试播客",
+		"author": "iuroc",
+		"image": "https://example.com/cover.png",
+		"episodes": [
+			{"file": "ep1.mp3", "title": "第一期", "date": "2024-08-01", "duration": "1:02:03"},
+			{"url": "https://files.imdodo.com/dodo/abc.m4a", "size": 100, "md5": "abc", "title": "第二期 & 完结", "date": "2024-08-08"}
+		]
+	}`), 0644)
+	config, err := LoadFeedConfig(filepath.Join(dir, "feed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := config.Resolve(&Uploader{DryRun: true}); failed != 0 {
+		t.Fatal("resolve failed")
+	}
+	var rss bytes.Buffer
+	if err = config.WriteRSS(&rss); err != nil {
+		t.Fatal(err)
+	}
+	feed := struct {
+		Items []struct {
+			Title     string `xml:"title"`
+			Enclosure struct {
+				URL    string `xml:"url,attr"`
+				Length int64  `xml:"length,attr"`
+				Type   string `xml:"type,attr"`
+			} `xml:"enclosure"`
+		} `xml:"channel>item"`
+	}{}
+	if err = xml.Unmarshal(rss.Bytes(), &feed); err != nil {
+		t.Fatal(err, rss.String())
+	}
+	if len(feed.Items) != 2 || feed.Items[0].Title != "第二期 & 完结" || feed.Items[0].Enclosure.Type != "audio/mp4" ||
+		feed.Items[1].Enclosure.Length != 9 || feed.Items[1].Enclosure.Type != "audio/mpeg" ||
+		!strings.HasPrefix(feed.Items[1].Enclosure.URL, "https://files.imdodo.com/dodo/") {
+		t.Fatalf("%+v\n%s", feed, rss.String())
+	}
+	if !strings.Contains(rss.String(), `<itunes:image href="https://example.com/cover.png"></itunes:image>`) {
+		t.Fatal(rss.String())
+	}
+	var m3u bytes.Buffer
+	config.WriteM3U(&m3u)
+	want := "#EXTM3U\n#PLAYLIST:测试播客\n#EXTINF:3723,第一期\n" + feed.Items[1].Enclosure.URL +
+		"\n#EXTINF:-1,第二期 & 完结\nhttps://files.imdodo.com/dodo/abc.m4a\n"
+	if m3u.String() != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", m3u.String(), want)
+	}
+}
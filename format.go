@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"mime"
+	"strings"
+	"text/template"
+)
+
+// Formats 支持的直链输出格式。包含 {{ 的格式视为 text/template 模板，参见 [FormatLink]。
+var Formats = []string{"plain", "md", "md-image", "html", "html-link", "bbcode", "json"}
+
+// FormatLink 按照输出格式 format 返回上传结果的直链文本。
+//
+//	plain:     https://files.imdodo.com/dodo/xxx.png
+//	md:        [a.png](https://files.imdodo.com/dodo/xxx.png)
+//	md-image:  ![a.png](https://files.imdodo.com/dodo/xxx.png)
+//	html:      根据扩展名选择 <img>、<video>、<audio> 或 <a>
+//	html-link: <a href="https://files.imdodo.com/dodo/xxx.png">a.png</a>
+//	bbcode:    图片使用 [img]，其他文件使用 [url]
+//	json:      上传结果的 JSON
+//
+// 模板可以使用 [UploadResult] 的全部字段，例如 {{.Base}}、{{.Ext}}、{{.MD5}}、{{.Size}} 和 {{.URL}}，
+// 以及函数 size（可读的文件大小）、mime（MIME 类型）和 kind（image、video、audio 或空）。
+func FormatLink(result *UploadResult, format string) (string, error) {
+	if strings.Contains(format, "{{") {
+		return executeTemplate(result, format)
+	}
+	escapedURL := html.EscapeString(result.URL)
+	escapedBase := html.EscapeString(result.Base)
+	switch format {
+	case "", "plain":
+		return result.URL, nil
+	case "md":
+		return fmt.Sprintf("[%s](%s)", markdownText(result.Base), result.URL), nil
+	case "md-image":
+		return fmt.Sprintf("![%s](%s)", markdownText(result.Base), result.URL), nil
+	case "html":
+		switch MediaKind(result.Ext) {
+		case "image":
+			return fmt.Sprintf(`<img src="%s" alt="%s">`, escapedURL, escapedBase), nil
+		case "video":
+			return fmt.Sprintf(`<video src="%s" controls></video>`, escapedURL), nil
+		case "audio":
+			return fmt.Sprintf(`<audio src="%s" controls></audio>`, escapedURL), nil
+		}
+		return fmt.Sprintf(`<a href="%s">%s</a>`, escapedURL, escapedBase), nil
+	case "html-link":
+		return fmt.Sprintf(`<a href="%s">%s</a>`, escapedURL, escapedBase), nil
+	case "bbcode":
+		if MediaKind(result.Ext) == "image" {
+			return fmt.Sprintf("[img]%s[/img]", result.URL), nil
+		}
+		return fmt.Sprintf("[url=%s]%s[/url]", result.URL, result.Base), nil
+	case "json":
+		data, err := json.Marshal(result)
+		return string(data), err
+	}
+	return "", fmt.Errorf("不支持的输出格式: %s", format)
+}
+
+func executeTemplate(result *UploadResult, format string) (string, error) {
+	tmpl, err := template.New("format").Funcs(template.FuncMap{
+		"size": HumanSize,
+		"mime": MediaType,
+		"kind": MediaKind,
+	}).Parse(format)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	if err = tmpl.Execute(&b, result); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// markdownText 转义 Markdown 链接文本中的方括号。
+func markdownText(text string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(text)
+}
+
+// MediaKind 根据扩展名判断媒体类型，返回 image、video、audio，其他文件返回空字符串。
+func MediaKind(ext string) string {
+	switch strings.ToLower(ext) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".webp", ".bmp", ".svg", ".avif", ".ico":
+		return "image"
+	case ".mp4", ".webm", ".mov", ".m4v", ".ogv", ".mkv":
+		return "video"
+	case ".mp3", ".m4a", ".aac", ".ogg", ".oga", ".wav", ".flac", ".opus":
+		return "audio"
+	}
+	return ""
+}
+
+// mediaTypes 系统 MIME 类型表中可能缺少的音视频类型。
+var mediaTypes = map[string]string{
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".aac":  "audio/aac",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".flac": "audio/flac",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".webm": "video/webm",
+	".mov":  "video/quicktime",
+	".ogv":  "video/ogg",
+	".mkv":  "video/x-matroska",
+	".m3u8": "application/vnd.apple.mpegurl",
+	".ts":   "video/mp2t",
+}
+
+// MediaType 根据扩展名返回 MIME 类型，未知类型返回 application/octet-stream。
+func MediaType(ext string) string {
+	ext = strings.ToLower(ext)
+	if mediaType, ok := mediaTypes[ext]; ok {
+		return mediaType
+	}
+	if mediaType := mime.TypeByExtension(ext); mediaType != "" {
+		return mediaType
+	}
+	return "application/octet-stream"
+}
+
+// HumanSize 返回可读的文件大小，例如 1.5 MB。
+func HumanSize(size int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	value := float64(size)
+	index := 0
+	for value >= 1024 && index < len(units)-1 {
+		value /= 1024
+		index++
+	}
+	if index == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[index])
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestFormatLink(t *testing.T) {
+	image := &UploadResult{Base: "a.png", Ext: ".png", MD5: "abc", Size: 1536, URL: "https://files.imdodo.com/dodo/abc.png"}
+	video := &UploadResult{Base: "b.mp4", Ext: ".mp4", URL: "https://files.imdodo.com/dodo/def.mp4"}
+	file := &UploadResult{Base: "c&d.zip", Ext: ".zip", URL: "https://files.imdodo.com/dodo/ghi.zip"}
+	tests := []struct {
+		result *UploadResult
+		format string
+		want   string
+	}{
+		{image, "plain", "https://files.imdodo.com/dodo/abc.png"},
+		{image, "md", "[a.png](https://files.imdodo.com/dodo/abc.png)"},
+		{image, "md-image", "![a.png](https://files.imdodo.com/dodo/abc.png)"},
+		{image, "html", `<img src="https://files.imdodo.com/dodo/abc.png" alt="a.png">`},
+		{video, "html", `<video src="https://files.imdodo.com/dodo/def.mp4" controls></video>`},
+		{file, "html", `<a href="https://files.imdodo.com/dodo/ghi.zip">c&amp;d.zip</a>`},
+		{image, "bbcode", "[img]https://files.imdodo.com/dodo/abc.png[/img]"},
+		{file, "bbcode", "[url=https://files.imdodo.com/dodo/ghi.zip]c&d.zip[/url]"},
+		{image, "{{.Base}} {{.MD5}} {{size .Size}} {{kind .Ext}} {{.URL}}", "a.png abc 1.5 KB image https://files.imdodo.com/dodo/abc.png"},
+	}
+	for _, test := range tests {
+		got, err := FormatLink(test.result, test.format)
+		if err != nil || got != test.want {
+			t.Errorf("FormatLink(%q) = %q, %v, want %q", test.format, got, err, test.want)
+		}
+	}
+	if _, err := FormatLink(image, "nope"); err == nil {
+		t.Fatal("未检查出错误的输出格式")
+	}
+}
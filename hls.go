@@ -0,0 +1,233 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var hlsURIAttr = regexp.MustCompile(`URI="([^"]*)"`)
+
+// ErrUploadFailed 至少一个文件上传失败。
+var ErrUploadFailed = errors.New("部分文件上传失败")
+
+// HLSPublisher 将 HLS 播放列表及其引用的分片、密钥和子播放列表上传到 DoDo。
+//
+// 子播放列表先于引用它的播放列表上传，播放列表中的引用替换为直链后再上传，最终得到可以直接播放的主播放列表直链。
+type HLSPublisher struct {
+	Uploader *Uploader
+	// 同时上传的分片数量。
+	Jobs int
+	// 保存替换引用后的播放列表的临时目录。
+	TempDir string
+	// 已上传的播放列表，键为原播放列表的绝对路径。
+	playlists map[string]*UploadResult
+}
+
+// FindPlaylistRefs 查找 m3u8 播放列表中的 URI 行和标签的 URI 属性，路径相对于 baseDir。
+//
+// 远程地址会被忽略，引用的本地文件不存在时返回错误。
+func FindPlaylistRefs(playlist []byte, baseDir string) ([]LocalRef, error) {
+	text := string(playlist)
+	refs := []LocalRef{}
+	offset := 0
+	for _, line := range strings.SplitAfter(text, "\n") {
+		start := offset
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+		ranges := [][]int{}
+		if strings.HasPrefix(trimmed, "#EXT") {
+			ranges = submatchRanges(hlsURIAttr.FindAllStringSubmatchIndex(line, -1))
+		} else if !strings.HasPrefix(trimmed, "#") {
+			index := strings.Index(line, trimmed)
+			ranges = append(ranges, []int{index, index + len(trimmed)})
+		}
+		for _, r := range ranges {
+			target := line[r[0]:r[1]]
+			if strings.HasPrefix(target, "//") || urlScheme.MatchString(target) {
+				continue
+			}
+			path, suffix, ok := ResolveLocalTarget(target, baseDir)
+			if !ok {
+				return nil, fmt.Errorf("引用的文件不存在: %s", target)
+			}
+			refs = append(refs, LocalRef{Start: start + r[0], End: start + r[1], Target: target, Path: path, Suffix: suffix})
+		}
+	}
+	return refs, nil
+}
+
+// Publish 上传播放列表 path 及其引用的全部文件，返回替换引用后的播放列表的上传结果。
+func (p *HLSPublisher) Publish(path string) (*UploadResult, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if p.playlists == nil {
+		p.playlists = map[string]*UploadResult{}
+	}
+	return p.publish(path, map[string]bool{})
+}
+
+// publish 按自底向上的顺序上传播放列表，visiting 记录正在处理的播放列表，用于检查循环引用。
+func (p *HLSPublisher) publish(path string, visiting map[string]bool) (*UploadResult, error) {
+	if result, ok := p.playlists[path]; ok {
+		return result, nil
+	}
+	if visiting[path] {
+		return nil, fmt.Errorf("播放列表循环引用: %s", path)
+	}
+	visiting[path] = true
+	defer delete(visiting, path)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	refs, err := FindPlaylistRefs(content, filepath.Dir(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	urls := map[string]string{}
+	files := []string{}
+	for _, ref := range refs {
+		if _, ok := urls[ref.Path]; ok {
+			continue
+		}
+		if isPlaylistFile(ref.Path) {
+			result, err := p.publish(ref.Path, visiting)
+			if err != nil {
+				return nil, err
+			}
+			urls[ref.Path] = result.URL
+			continue
+		}
+		urls[ref.Path] = ""
+		files = append(files, ref.Path)
+	}
+	if err = p.uploadFiles(filepath.Base(path), files, urls); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(p.TempDir, strconv.Itoa(len(p.playlists)))
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	rewritten := filepath.Join(dir, filepath.Base(path))
+	if err = os.WriteFile(rewritten, ReplaceRefs(content, refs, urls), 0644); err != nil {
+		return nil, err
+	}
+	// 临时文件不记录到清单。
+	uploader := &Uploader{UserInfo: p.Uploader.UserInfo, DryRun: p.Uploader.DryRun}
+	result, err := uploader.Upload(rewritten)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	fmt.Fprintf(os.Stderr, "%s -> %s\n", filepath.Base(path), result.URL)
+	p.playlists[path] = result
+	return result, nil
+}
+
+// uploadFiles 同时上传多个文件，将直链写入 urls，并在标准错误中输出进度。
+func (p *HLSPublisher) uploadFiles(name string, files []string, urls map[string]string) error {
+	jobs := p.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	paths := make(chan string)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	done, failed := 0, 0
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				result, err := p.Uploader.Upload(path)
+				mu.Lock()
+				done++
+				if err != nil {
+					failed++
+					fmt.Fprintf(os.Stderr, "❗ 错误: [%s %d/%d] %s: %s\n", name, done, len(files), filepath.Base(path), err)
+				} else {
+					urls[path] = result.URL
+					fmt.Fprintf(os.Stderr, "[%s %d/%d] %s -> %s\n", name, done, len(files), filepath.Base(path), result.URL)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, path := range files {
+		paths <- path
+	}
+	close(paths)
+	wg.Wait()
+	if failed > 0 {
+		return fmt.Errorf("%s: %w (%d/%d)", name, ErrUploadFailed, failed, len(files))
+	}
+	return nil
+}
+
+func isPlaylistFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".m3u8" || ext == ".m3u"
+}
+
+func cmdHLS(args []string) int {
+	flags := newFlagSet("hls")
+	jobs := flags.Int("j", 4, "同时上传的分片数量")
+	manifestPath := flags.String("manifest", DataPath("manifest.json"), "清单文件，未变化的分片不会重新上传")
+	dryRun := flags.Bool("dry-run", false, "不上传文件，只输出将要替换的引用和主播放列表的直链")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if _, err := os.Stat(flags.Arg(0)); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 文件不存在", flags.Arg(0))
+		return ExitNotFound
+	}
+	manifest, err := LoadManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	uploader := &Uploader{DryRun: *dryRun, Manifest: manifest}
+	if !*dryRun {
+		userInfo, code := requireUserInfo()
+		if userInfo == nil {
+			return code
+		}
+		uploader.UserInfo = userInfo
+	}
+	tempDir, err := os.MkdirTemp("", "gododo-hls-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	defer os.RemoveAll(tempDir)
+	publisher := &HLSPublisher{Uploader: uploader, Jobs: *jobs, TempDir: tempDir}
+	result, err := publisher.Publish(flags.Arg(0))
+	if !*dryRun {
+		if err := manifest.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		}
+	}
+	if errors.Is(err, ErrUploadFailed) {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitUpload
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	fmt.Println(result.URL)
+	return ExitOK
+}
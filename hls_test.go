@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPublishHLS(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "720p"), 0755)
+	os.WriteFile(filepath.Join(dir, "720p", "seg0.ts"), []byte("segment 0"), 0644)
+	os.WriteFile(filepath.Join(dir, "720p", "seg1.ts"), []byte("segment 1"), 0644)
+	os.WriteFile(filepath.Join(dir, "key.bin"), []byte("0123456789abcdef"), 0644)
+	os.WriteFile(filepath.Join(dir, "720p", "index.m3u8"), []byte("#EXTM3U\r\n"+
+		"#EXT-X-KEY:METHOD=AES-128,URI=\"../key.bin\"\r\n"+
+		"#EXTINF:4.0,\r\nseg0.ts\r\n#EXTINF:4.0,\r\nseg1.ts\r\n"+
+		"#EXTINF:4.0,\r\nhttps://cdn.example.com/seg2.ts\r\n"), 0644)
+	master := filepath.Join(dir, "master.m3u8")
+	os.WriteFile(master, []byte("#EXTM3U\n"+
+		"#EXT-X-STREAM-INF:BANDWIDTH=1280000\n720p/index.m3u8\n"+
+		"#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=86000,URI=\"720p/index.m3u8\"\n"), 0644)
+
+	publisher := &HLSPublisher{Uploader: &Uploader{DryRun: true}, Jobs: 2, TempDir: t.TempDir()}
+	result, err := publisher.Publish(master)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := os.ReadFile(result.Path)
+	lines := strings.Split(string(content), "\n")
+	media := lines[2]
+	if !strings.HasPrefix(media, "https://files.imdodo.com/dodo/") || !strings.HasSuffix(media, ".m3u8") {
+		t.Fatalf("master:\n%s", content)
+	}
+	if !strings.Contains(lines[3], `URI="`+media+`"`) {
+		t.Fatalf("master:\n%s", content)
+	}
+	content, _ = os.ReadFile(publisher.playlists[filepath.Join(dir, "720p", "index.m3u8")].Path)
+	text := string(content)
+	if strings.Contains(text, "seg0.ts\r") || strings.Contains(text, "../key.bin") || !strings.Contains(text, "https://cdn.example.com/seg2.ts\r\n") {
+		t.Fatalf("media:\n%s", content)
+	}
+
+	os.WriteFile(master, []byte("#EXTM3U\nmissing.ts\n"), 0644)
+	if _, err := (&HLSPublisher{Uploader: &Uploader{DryRun: true}, TempDir: t.TempDir()}).Publish(master); err == nil {
+		t.Fatal("missing segment should fail")
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// HooksFile 上传钩子配置文件名，位于 [DataPath] 指定的目录。
+const HooksFile = "hooks.json"
+
+// 钩子事件。
+const (
+	// 上传成功，并且已经记录到上传历史。
+	HookUpload = "upload"
+	// 上传失败。
+	HookFailure = "failure"
+)
+
+// Hook 上传成功或失败后执行的命令或 Webhook。
+//
+// 命令的标准输入为 [HookEvent] 的 JSON，同时设置 GODODO_ 开头的环境变量，命令的输出写入标准错误。
+// Webhook 以 POST 请求发送相同的 JSON，Secret 不为空时在 X-Gododo-Signature 头中携带
+// sha256= 加请求体的 HMAC-SHA256 签名。网络错误、429 和 5xx 响应会按 1 秒、2 秒、4 秒……的间隔重试。
+type Hook struct {
+	// 触发的事件，upload 或 failure，为空时为 upload。
+	On string `json:"on,omitempty"`
+	// 可执行文件及其参数，不经过 shell。
+	Command []string `json:"command,omitempty"`
+	URL     string   `json:"url,omitempty"`
+	Secret  string   `json:"secret,omitempty"`
+	// Webhook 失败后的重试次数。
+	Retries int `json:"retries,omitempty"`
+	// 每次执行的超时时间，单位为秒，默认 30 秒。
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// HookEvent 传递给钩子的事件。
+type HookEvent struct {
+	Event  string        `json:"event"`
+	Path   string        `json:"path"`
+	Result *UploadResult `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+	Time   time.Time     `json:"time"`
+}
+
+// ShellHook 返回使用系统的 shell 执行 command 的钩子。
+func ShellHook(command string) *Hook {
+	if runtime.GOOS == "windows" {
+		return &Hook{Command: []string{"cmd", "/C", command}}
+	}
+	return &Hook{Command: []string{"sh", "-c", command}}
+}
+
+// LoadHooks 读取钩子配置文件，文件不存在时返回空列表。
+//
+//	{"hooks": [{"on": "upload", "command": ["./notify.sh"]}, {"on": "failure", "url": "http://127.0.0.1:8080/hook", "secret": "..."}]}
+func LoadHooks(path string) ([]*Hook, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var config struct {
+		Hooks []*Hook `json:"hooks"`
+	}
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	for i, hook := range config.Hooks {
+		if hook.On == "" {
+			hook.On = HookUpload
+		}
+		if hook.On != HookUpload && hook.On != HookFailure {
+			return nil, fmt.Errorf("%s: 第 %d 个钩子的 on 应为 upload 或 failure", path, i+1)
+		}
+		if (len(hook.Command) == 0) == (hook.URL == "") {
+			return nil, fmt.Errorf("%s: 第 %d 个钩子需要 command 或 url 其中之一", path, i+1)
+		}
+	}
+	return config.Hooks, nil
+}
+
+// 后台执行钩子的 goroutine 数量和等待执行的事件数量上限，队列已满时 [HookRunner.Send] 会阻塞。
+const (
+	hookWorkers = 4
+	hookBacklog = 256
+)
+
+// HookRunner 在后台执行钩子。
+type HookRunner struct {
+	Hooks []*Hook
+	// 执行单个钩子，为空时使用 [Hook.Run]。
+	Exec    func(ctx context.Context, hook *Hook, event *HookEvent) error
+	start   sync.Once
+	events  chan *HookEvent
+	pending sync.WaitGroup
+}
+
+// Send 将事件交给后台执行与事件匹配的钩子，不等待执行完成。钩子失败时只输出警告。
+func (r *HookRunner) Send(event *HookEvent) {
+	for _, hook := range r.Hooks {
+		if hook.On != event.Event {
+			continue
+		}
+		r.start.Do(func() {
+			r.events = make(chan *HookEvent, hookBacklog)
+			for i := 0; i < hookWorkers; i++ {
+				go r.worker()
+			}
+		})
+		r.pending.Add(1)
+		r.events <- event
+		return
+	}
+}
+
+// Wait 等待已经发送的事件全部执行完成。
+func (r *HookRunner) Wait() {
+	r.pending.Wait()
+}
+
+func (r *HookRunner) worker() {
+	for event := range r.events {
+		for _, hook := range r.Hooks {
+			if hook.On != event.Event {
+				continue
+			}
+			var err error
+			if r.Exec != nil {
+				err = r.Exec(context.Background(), hook, event)
+			} else {
+				err = hook.Run(context.Background(), event)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️ 钩子执行失败: %s: %s\n", event.Path, err)
+			}
+		}
+		r.pending.Done()
+	}
+}
+
+var (
+	hooksOnce sync.Once
+	// 第一次上传时根据 [HooksFile] 创建。
+	hookRunner atomic.Pointer[HookRunner]
+)
+
+// runHooks 在后台执行钩子配置文件中与事件匹配的钩子，退出前需要调用 [WaitHooks]。
+func runHooks(event *HookEvent) {
+	hooksOnce.Do(func() {
+		hooks, err := LoadHooks(DataPath(HooksFile))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "⚠️ 钩子配置读取失败:", err)
+		}
+		hookRunner.Store(&HookRunner{Hooks: hooks})
+	})
+	hookRunner.Load().Send(event)
+}
+
+// WaitHooks 等待后台的钩子全部执行完成。
+func WaitHooks() {
+	if runner := hookRunner.Load(); runner != nil {
+		runner.Wait()
+	}
+}
+
+// uploadHooks 在上传成功后或失败后在后台执行钩子，上传被取消时不执行。
+func uploadHooks(path string, result *UploadResult, err error) {
+	if event := newUploadEvent(path, result, err); event != nil {
+		runHooks(event)
+	}
+}
+
+// newUploadEvent 返回上传结果对应的事件，上传被取消时返回 nil。
+func newUploadEvent(path string, result *UploadResult, err error) *HookEvent {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	event := &HookEvent{Event: HookUpload, Path: path, Time: time.Now()}
+	if err != nil {
+		event.Event, event.Error = HookFailure, err.Error()
+	} else {
+		// 钩子在后台执行，调用方之后可能修改上传结果，例如设置二维码路径。
+		copied := *result
+		event.Path, event.Result = result.Path, &copied
+	}
+	return event
+}
+
+// Run 执行钩子。
+func (h *Hook) Run(ctx context.Context, event *HookEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	timeout := 30 * time.Second
+	if h.Timeout > 0 {
+		timeout = time.Duration(h.Timeout) * time.Second
+	}
+	if len(h.Command) > 0 {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		// 标准输出可能用于 rpc 和 lfs-agent 的协议，命令的输出一律写入标准错误。
+		return h.exec(ctx, event, data, os.Stderr)
+	}
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		err = h.post(ctx, event, data, timeout)
+		var status hookStatusError
+		if err == nil || attempt >= h.Retries || (errors.As(err, &status) && !status.retry()) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// exec 执行命令，stdin 为命令的标准输入，命令的标准输出写入 stdout。
+func (h *Hook) exec(ctx context.Context, event *HookEvent, stdin []byte, stdout io.Writer) error {
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(append(stdin, '\n'))
+	cmd.Stdout = stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), "GODODO_EVENT="+event.Event, "GODODO_PATH="+event.Path, "GODODO_ERROR="+event.Error)
+	if result := event.Result; result != nil {
+		cmd.Env = append(cmd.Env,
+			"GODODO_NAME="+result.Base,
+			"GODODO_MD5="+result.MD5,
+			"GODODO_SIZE="+strconv.FormatInt(result.Size, 10),
+			"GODODO_URL="+result.URL,
+		)
+	}
+	return cmd.Run()
+}
+
+// hookStatusError Webhook 返回的错误状态码。
+type hookStatusError int
+
+func (e hookStatusError) Error() string {
+	return "Webhook 返回 " + strconv.Itoa(int(e)) + " " + http.StatusText(int(e))
+}
+
+func (e hookStatusError) retry() bool {
+	return e == http.StatusTooManyRequests || e >= 500
+}
+
+func (h *Hook) post(ctx context.Context, event *HookEvent, data []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "gododo")
+	request.Header.Set("X-Gododo-Event", event.Event)
+	if h.Secret != "" {
+		request.Header.Set("X-Gododo-Signature", "sha256="+SignHook(h.Secret, data))
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return hookStatusError(response.StatusCode)
+	}
+	return nil
+}
+
+// SignHook 返回请求体的 HMAC-SHA256 签名，接收方可以用相同的密钥校验 X-Gododo-Signature 头。
+func SignHook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestLoadHooks(t *testing.T) {
+	dir := t.TempDir()
+	if hooks, err := LoadHooks(filepath.Join(dir, "none.json")); hooks != nil || err != nil {
+		t.Errorf("LoadHooks(missing) = %v, %v", hooks, err)
+	}
+	tests := map[string]string{
+		`{"hooks": [{"command": ["true"]}, {"on": "failure", "url": "http://127.0.0.1/"}]}`: "",
+		`{"hooks": [{"on": "done", "command": ["true"]}]}`:                                  "upload 或 failure",
+		`{"hooks": [{"command": ["true"], "url": "http://127.0.0.1/"}]}`:                    "command 或 url",
+		`{"hooks": [{}]}`: "command 或 url",
+	}
+	for config, want := range tests {
+		path := filepath.Join(dir, HooksFile)
+		os.WriteFile(path, []byte(config), 0644)
+		hooks, err := LoadHooks(path)
+		if want == "" {
+			if err != nil || len(hooks) != 2 || hooks[0].On != HookUpload {
+				t.Errorf("LoadHooks(%s) = %v, %v", config, hooks, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadHooks(%s) error = %v, want %q", config, err, want)
+		}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Gododo-Signature"), "sha256="+SignHook("secret", body); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		var event HookEvent
+		if err := json.Unmarshal(body, &event); err != nil || event.Result.URL != "https://files.imdodo.com/dodo/a.png" {
+			t.Errorf("body = %s", body)
+		}
+		if r.URL.Path == "/bad" {
+			w.WriteHeader(http.StatusBadRequest)
+		} else if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	event := &HookEvent{Event: HookUpload, Path: "a.png", Result: &UploadResult{URL: "https://files.imdodo.com/dodo/a.png"}}
+	hook := &Hook{On: HookUpload, URL: server.URL, Secret: "secret", Retries: 2}
+	if err := hook.Run(context.Background(), event); err != nil || requests != 2 {
+		t.Errorf("Run() = %v after %d requests", err, requests)
+	}
+	requests = 0
+	hook.URL = server.URL + "/bad"
+	if err := hook.Run(context.Background(), event); err == nil || requests != 1 {
+		t.Errorf("Run(/bad) = %v after %d requests", err, requests)
+	}
+}
+
+func TestCommandHook(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 sh")
+	}
+	out := filepath.Join(t.TempDir(), "out")
+	hook := ShellHook(`cat > "$OUT"; echo "$GODODO_EVENT $GODODO_URL" >> "$OUT"`)
+	t.Setenv("OUT", out)
+	event := &HookEvent{Event: HookUpload, Path: "a.png", Result: &UploadResult{URL: "https://files.imdodo.com/dodo/a.png"}}
+	if err := hook.Run(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(out)
+	if !strings.HasPrefix(string(data), `{"event":"upload","path":"a.png","result":{`) || !strings.HasSuffix(string(data), "\nupload https://files.imdodo.com/dodo/a.png\n") {
+		t.Errorf("hook output = %s", data)
+	}
+	if err := ShellHook("exit 3").Run(context.Background(), event); err == nil {
+		t.Error("failed command returned nil")
+	}
+}
+
+func TestHookRunner(t *testing.T) {
+	release := make(chan struct{})
+	var mu sync.Mutex
+	got := []string{}
+	runner := &HookRunner{
+		Hooks: []*Hook{{On: HookFailure, URL: "a"}, {On: HookUpload, URL: "b"}, {On: HookFailure, URL: "c"}},
+		Exec: func(ctx context.Context, hook *Hook, event *HookEvent) error {
+			<-release
+			mu.Lock()
+			defer mu.Unlock()
+			got = append(got, hook.URL+" "+event.Path)
+			return nil
+		},
+	}
+	// 钩子被阻塞时 Send 仍然立即返回。
+	runner.Send(newUploadEvent("a.png", nil, errors.New("boom")))
+	if event := newUploadEvent("b.png", nil, context.Canceled); event != nil {
+		t.Errorf("canceled upload event = %+v", event)
+	}
+	close(release)
+	runner.Wait()
+	if want := []string{"a a.png", "c a.png"}; !slices.Equal(got, want) {
+		t.Errorf("hooks ran %v, want %v", got, want)
+	}
+}
+
+func TestNewUploadEvent(t *testing.T) {
+	result := &UploadResult{Path: "/abs/a.png", URL: "https://files.imdodo.com/dodo/a.png"}
+	event := newUploadEvent("a.png", result, nil)
+	result.QRCode = "qr.png"
+	if event.Event != HookUpload || event.Path != "/abs/a.png" || event.Result.QRCode != "" {
+		t.Errorf("event = %+v", event)
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// IndexObject 索引中的单个对象。
+type IndexObject struct {
+	URL         string    `json:"url"`
+	MD5         string    `json:"md5"`
+	Size        int64     `json:"size"`
+	ContentType string    `json:"contentType,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// ObjectIndex 以 / 分隔的对象键到直链的索引，每次修改后保存到文件，可以在多个 goroutine 中同时使用。
+type ObjectIndex struct {
+	path    string
+	mu      sync.Mutex
+	Objects map[string]*IndexObject `json:"objects"`
+	// 没有对象的空目录。
+	Dirs map[string]bool `json:"dirs,omitempty"`
+}
+
+// LoadObjectIndex 读取索引文件，文件不存在时返回空索引。
+func LoadObjectIndex(path string) (*ObjectIndex, error) {
+	index := &ObjectIndex{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		if err = json.Unmarshal(data, index); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if index.Objects == nil {
+		index.Objects = map[string]*IndexObject{}
+	}
+	if index.Dirs == nil {
+		index.Dirs = map[string]bool{}
+	}
+	return index, nil
+}
+
+// Get 返回对象，不存在时返回 nil。
+func (index *ObjectIndex) Get(key string) *IndexObject {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	return index.Objects[key]
+}
+
+// Put 记录对象并保存索引。
+func (index *ObjectIndex) Put(key string, object *IndexObject) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.Objects[key] = object
+	return index.save()
+}
+
+// save 写入临时文件后替换索引文件，避免中断时留下不完整的文件，调用时需要持有锁。
+func (index *ObjectIndex) save() error {
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(index.path), 0755); err != nil {
+		return err
+	}
+	temp := index.path + ".tmp"
+	if err = os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, index.path)
+}
+
+// IsDir 判断目录是否存在，根目录和包含对象的目录总是存在。
+func (index *ObjectIndex) IsDir(dir string) bool {
+	if dir == "" {
+		return true
+	}
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	return index.isDir(dir)
+}
+
+func (index *ObjectIndex) isDir(dir string) bool {
+	if index.Dirs[dir] {
+		return true
+	}
+	for key := range index.Objects {
+		if strings.HasPrefix(key, dir+"/") {
+			return true
+		}
+	}
+	for key := range index.Dirs {
+		if strings.HasPrefix(key, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// MakeDir 创建空目录并保存索引。
+func (index *ObjectIndex) MakeDir(dir string) error {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	index.Dirs[dir] = true
+	return index.save()
+}
+
+// List 返回目录下的子目录名和对象名，均按名称排序。
+func (index *ObjectIndex) List(dir string) (dirs []string, objects []string) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	prefix := ""
+	if dir != "" {
+		prefix = dir + "/"
+	}
+	seen := map[string]bool{}
+	add := func(key string, isObject bool) {
+		rest, ok := strings.CutPrefix(key, prefix)
+		if !ok || rest == "" {
+			return
+		}
+		name, _, nested := strings.Cut(rest, "/")
+		if nested || !isObject {
+			if !seen[name+"/"] {
+				seen[name+"/"] = true
+				dirs = append(dirs, name)
+			}
+		} else if !seen[name] {
+			seen[name] = true
+			objects = append(objects, name)
+		}
+	}
+	for key := range index.Objects {
+		add(key, true)
+	}
+	for key := range index.Dirs {
+		add(key, false)
+	}
+	sort.Strings(dirs)
+	sort.Strings(objects)
+	return dirs, objects
+}
+
+// Delete 删除对象或目录及其中的全部对象并保存索引，返回是否删除了内容。DoDo 上的文件不会被删除。
+func (index *ObjectIndex) Delete(key string) (bool, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	keys := index.keys(key)
+	if len(keys) == 0 {
+		return false, nil
+	}
+	for k := range keys {
+		delete(index.Objects, k)
+		delete(index.Dirs, k)
+	}
+	return true, index.save()
+}
+
+// keys 返回 key 本身及以 key/ 开头的全部对象和目录，调用时需要持有锁。
+func (index *ObjectIndex) keys(key string) map[string]bool {
+	keys := map[string]bool{}
+	for k := range index.Objects {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			keys[k] = true
+		}
+	}
+	for k := range index.Dirs {
+		if k == key || strings.HasPrefix(k, key+"/") {
+			keys[k] = true
+		}
+	}
+	return keys
+}
+
+// Move 将对象或目录移动到 to，copy 为 true 时保留原位置，返回是否移动了内容。
+func (index *ObjectIndex) Move(from string, to string, copy bool) (bool, error) {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+	keys := index.keys(from)
+	if len(keys) == 0 {
+		return false, nil
+	}
+	for k := range index.keys(to) {
+		delete(index.Objects, k)
+		delete(index.Dirs, k)
+	}
+	for k := range keys {
+		target := to + strings.TrimPrefix(k, from)
+		if object, ok := index.Objects[k]; ok {
+			index.Objects[target] = object
+		} else {
+			index.Dirs[target] = true
+		}
+		if !copy {
+			delete(index.Objects, k)
+			delete(index.Dirs, k)
+		}
+	}
+	if dir := path.Dir(from); !copy && dir != "." && !index.isDir(dir) {
+		// 保留移动后变为空的目录。
+		index.Dirs[dir] = true
+	}
+	return true, index.save()
+}
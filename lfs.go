@@ -0,0 +1,267 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/iuroc/gododo/dodo"
+)
+
+// LFSMapFile 仓库中记录 LFS 对象直链的文件，需要与代码一起提交，其他克隆才能下载对象。
+const LFSMapFile = ".gododo-lfs.json"
+
+// LFSMap LFS 对象 ID（SHA-256）到 DoDo 直链的映射。
+type LFSMap struct {
+	path    string
+	Objects map[string]string `json:"objects"`
+}
+
+// LoadLFSMap 读取映射文件，文件不存在时返回空映射。
+func LoadLFSMap(path string) (*LFSMap, error) {
+	m := &LFSMap{path: path, Objects: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return m, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if m.Objects == nil {
+		m.Objects = map[string]string{}
+	}
+	return m, nil
+}
+
+// Save 保存映射到读取时的路径，对象按 ID 排序，便于在版本控制中比较差异。
+//
+// 先写入临时文件再替换，推送中途被终止时不会丢失已有的映射。
+func (m *LFSMap) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	temp := m.path + ".tmp"
+	if err = os.WriteFile(temp, append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, m.path)
+}
+
+// LFSAgent Git LFS 独立自定义传输代理，通过标准输入输出以 JSON Lines 与 git-lfs 通信。
+//
+// 上传的对象通过 [dodo.UploadWork] 发布到 DoDo，下载的对象从映射文件中记录的直链获取。
+type LFSAgent struct {
+	UserInfo *UserInfo
+	Map      *LFSMap
+	// 下载对象的临时目录，需要与 LFS 对象目录位于同一文件系统。
+	TempDir string
+	encoder *json.Encoder
+}
+
+// LFSError 传输失败时返回给 git-lfs 的错误。
+type LFSError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// lfsEvent git-lfs 发送的事件。
+type lfsEvent struct {
+	Event     string `json:"event"`
+	Operation string `json:"operation"`
+	OID       string `json:"oid"`
+	Size      int64  `json:"size"`
+	Path      string `json:"path"`
+}
+
+type lfsProgress struct {
+	Event          string `json:"event"`
+	OID            string `json:"oid"`
+	BytesSoFar     int64  `json:"bytesSoFar"`
+	BytesSinceLast int64  `json:"bytesSinceLast"`
+}
+
+type lfsComplete struct {
+	Event string    `json:"event"`
+	OID   string    `json:"oid"`
+	Path  string    `json:"path,omitempty"`
+	Error *LFSError `json:"error,omitempty"`
+}
+
+// Serve 处理 git-lfs 发送的事件，直到收到 terminate 事件或输入结束。
+func (a *LFSAgent) Serve(r io.Reader, w io.Writer) error {
+	a.encoder = json.NewEncoder(w)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		event := &lfsEvent{}
+		if err := json.Unmarshal(scanner.Bytes(), event); err != nil {
+			return err
+		}
+		var err error
+		switch event.Event {
+		case "init":
+			err = a.init(event)
+		case "upload":
+			err = a.complete(event.OID, "", a.upload(event))
+		case "download":
+			path, downloadErr := a.download(event)
+			err = a.complete(event.OID, path, downloadErr)
+		case "terminate":
+			return nil
+		default:
+			err = a.encoder.Encode(map[string]any{"error": LFSError{Code: 1, Message: "未知事件: " + event.Event}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (a *LFSAgent) init(event *lfsEvent) error {
+	// 只有上传需要登录。
+	if event.Operation == "upload" && a.UserInfo == nil {
+		userInfo, err := LoadUserInfo()
+		if err != nil {
+			return a.encoder.Encode(map[string]any{"error": LFSError{Code: ExitAuth, Message: err.Error() + "，请先运行 gododo login"}})
+		}
+		a.UserInfo = userInfo
+	}
+	return a.encoder.Encode(struct{}{})
+}
+
+// complete 发送传输结果，err 不为空时表示传输失败。
+func (a *LFSAgent) complete(oid string, path string, err error) error {
+	message := lfsComplete{Event: "complete", OID: oid, Path: path}
+	if err != nil {
+		message.Path = ""
+		message.Error = &LFSError{Code: 2, Message: err.Error()}
+	}
+	return a.encoder.Encode(message)
+}
+
+// progress 发送传输进度。
+func (a *LFSAgent) progress(oid string, sent int64, last *int64) {
+	a.encoder.Encode(lfsProgress{Event: "progress", OID: oid, BytesSoFar: sent, BytesSinceLast: sent - *last})
+	*last = sent
+}
+
+func (a *LFSAgent) upload(event *lfsEvent) error {
+	if _, ok := a.Map.Objects[event.OID]; ok {
+		return nil
+	}
+	if a.UserInfo == nil {
+		return ErrNotLoggedIn
+	}
+	work, err := dodo.NewUploadWork(event.Path, a.UserInfo.Token, a.UserInfo.UID)
+	if err != nil {
+		return err
+	}
+	var last int64
+	work.Progress = func(sent int64, total int64) {
+		a.progress(event.OID, sent, &last)
+	}
+	result, err := publishWork(context.Background(), work, "")
+	if err != nil {
+		return err
+	}
+	if result.Cached {
+		a.progress(event.OID, event.Size, &last)
+	}
+	a.Map.Objects[event.OID] = result.URL
+	return a.Map.Save()
+}
+
+func (a *LFSAgent) download(event *lfsEvent) (string, error) {
+	resourceURL, ok := a.Map.Objects[event.OID]
+	if !ok {
+		return "", fmt.Errorf("%s 中没有对象 %s 的直链", LFSMapFile, event.OID)
+	}
+	response, err := http.Get(resourceURL)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return "", errors.New(response.Status)
+	}
+	if err = os.MkdirAll(a.TempDir, 0755); err != nil {
+		return "", err
+	}
+	file, err := os.CreateTemp(a.TempDir, event.OID+"-")
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	var last int64
+	content := &dodo.ProgressReader{
+		Reader: io.TeeReader(response.Body, hash),
+		Total:  event.Size,
+		Progress: func(sent int64, total int64) {
+			a.progress(event.OID, sent, &last)
+		},
+	}
+	_, err = io.Copy(file, content)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && hex.EncodeToString(hash.Sum(nil)) != event.OID {
+		err = errors.New("下载的文件与对象 ID 不一致")
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// gitPath 返回 git rev-parse 的输出，git 不可用时返回 fallback。
+func gitPath(fallback string, args ...string) string {
+	output, err := exec.Command("git", append([]string{"rev-parse"}, args...)...).Output()
+	if err != nil {
+		return fallback
+	}
+	path, err := filepath.Abs(strings.TrimSpace(string(output)))
+	if err != nil {
+		return fallback
+	}
+	return path
+}
+
+func cmdLFSAgent(args []string) int {
+	flags := newFlagSet("lfs-agent")
+	mapPath := flags.String("map", "", "对象直链映射文件，默认为仓库根目录下的 "+LFSMapFile)
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if *mapPath == "" {
+		*mapPath = filepath.Join(gitPath(".", "--show-toplevel"), LFSMapFile)
+	}
+	m, err := LoadLFSMap(*mapPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	agent := &LFSAgent{
+		Map:     m,
+		TempDir: gitPath(os.TempDir(), "--git-path", "lfs/tmp"),
+	}
+	if err = agent.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	return ExitOK
+}
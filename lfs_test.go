@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLFSAgentDownload(t *testing.T) {
+	content := []byte("large binary asset")
+	sum := sha256.Sum256(content)
+	oid := hex.EncodeToString(sum[:])
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(content)
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	m, _ := LoadLFSMap(filepath.Join(dir, LFSMapFile))
+	m.Objects[oid] = server.URL + "/dodo/abc"
+	agent := &LFSAgent{Map: m, TempDir: filepath.Join(dir, "tmp")}
+	input := `{"event":"init","operation":"download","remote":"origin","concurrent":true,"concurrenttransfers":3}
+{"event":"download","oid":"` + oid + `","size":18,"action":null}
+{"event":"download","oid":"missing","size":1,"action":null}
+{"event":"terminate"}
+`
+	var output strings.Builder
+	if err := agent.Serve(strings.NewReader(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	messages := []map[string]any{}
+	scanner := bufio.NewScanner(strings.NewReader(output.String()))
+	for scanner.Scan() {
+		message := map[string]any{}
+		json.Unmarshal(scanner.Bytes(), &message)
+		messages = append(messages, message)
+	}
+	if len(messages) != 4 || len(messages[0]) != 0 || messages[1]["event"] != "progress" || messages[1]["bytesSoFar"] != 18.0 {
+		t.Fatal(output.String())
+	}
+	path, _ := messages[2]["path"].(string)
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(content) {
+		t.Fatal(output.String())
+	}
+	if messages[3]["error"] == nil {
+		t.Fatal(output.String())
+	}
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrInterrupt 用户在输入时按下了 Ctrl-C。
+var ErrInterrupt = errors.New("interrupt")
+
+// LineReader 逐行读取用户输入。
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// NewLineReader 在终端中返回支持行编辑的 [LineEditor]，否则返回逐行读取的 [ScanLineReader]。
+//
+// complete 用于 Tab 补全，参数为光标前的内容，返回补全后的内容和多个候选项。
+func NewLineReader(in *os.File, out io.Writer, complete func(head string) (string, []string)) LineReader {
+	if isTerminal(int(in.Fd())) {
+		return &LineEditor{
+			in:       in,
+			out:      out,
+			reader:   bufio.NewReader(in),
+			Complete: complete,
+		}
+	}
+	return &ScanLineReader{
+		scanner: bufio.NewScanner(in),
+		out:     out,
+	}
+}
+
+// ScanLineReader 不支持行编辑的输入，用于管道和不支持 raw 模式的平台。
+type ScanLineReader struct {
+	scanner *bufio.Scanner
+	out     io.Writer
+}
+
+func (r *ScanLineReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(r.out, prompt)
+	if !r.scanner.Scan() {
+		fmt.Fprintln(r.out)
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// LineEditor 支持光标移动、历史记录和 Tab 补全的终端输入。
+//
+// 支持的按键：←/→、Home/End、Ctrl-A/Ctrl-E、↑/↓ 切换历史记录、Backspace/Delete、
+// Ctrl-U/Ctrl-K 删除光标前/后的内容、Ctrl-W 删除前一个词、Ctrl-C 取消输入、Ctrl-D 退出。
+type LineEditor struct {
+	in       *os.File
+	out      io.Writer
+	reader   *bufio.Reader
+	History  []string
+	Complete func(head string) (string, []string)
+	// 上次绘制后光标所在的行，相对于提示符所在的行。
+	cursorRow int
+}
+
+func (e *LineEditor) ReadLine(prompt string) (string, error) {
+	restore, err := makeRaw(int(e.in.Fd()))
+	if err != nil {
+		return "", err
+	}
+	defer restore()
+	e.cursorRow = 0
+	line := []rune{}
+	pos := 0
+	historyIndex := len(e.History)
+	lastTab := false
+	e.refresh(prompt, line, pos)
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		tab := false
+		switch r {
+		case '\r', '\n':
+			e.refresh(prompt, line, len(line))
+			fmt.Fprint(e.out, "\r\n")
+			if text := strings.TrimSpace(string(line)); text != "" {
+				if len(e.History) == 0 || e.History[len(e.History)-1] != string(line) {
+					e.History = append(e.History, string(line))
+				}
+			}
+			return string(line), nil
+		case 3: // Ctrl-C
+			e.refresh(prompt, line, len(line))
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupt
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+			if pos < len(line) {
+				line = append(line[:pos], line[pos+1:]...)
+			}
+		case 127, 8: // Backspace
+			if pos > 0 {
+				line = append(line[:pos-1], line[pos:]...)
+				pos--
+			}
+		case 1: // Ctrl-A
+			pos = 0
+		case 5: // Ctrl-E
+			pos = len(line)
+		case 2: // Ctrl-B
+			if pos > 0 {
+				pos--
+			}
+		case 6: // Ctrl-F
+			if pos < len(line) {
+				pos++
+			}
+		case 21: // Ctrl-U
+			line = line[pos:]
+			pos = 0
+		case 11: // Ctrl-K
+			line = line[:pos]
+		case 23: // Ctrl-W
+			start := pos
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[pos:]...)
+			pos = start
+		case '\t':
+			tab = true
+			if e.Complete == nil {
+				break
+			}
+			head, candidates := e.Complete(string(line[:pos]))
+			if head != string(line[:pos]) {
+				tail := line[pos:]
+				line = append([]rune(head), tail...)
+				pos = len([]rune(head))
+			} else if len(candidates) > 1 && lastTab {
+				e.refresh(prompt, line, len(line))
+				fmt.Fprint(e.out, "\r\n"+strings.Join(candidates, "  ")+"\r\n")
+				e.cursorRow = 0
+			}
+		case 27: // 转义序列
+			line, pos, historyIndex = e.escape(line, pos, historyIndex)
+		default:
+			if r >= 32 {
+				line = append(line[:pos], append([]rune{r}, line[pos:]...)...)
+				pos++
+			}
+		}
+		lastTab = tab
+		e.refresh(prompt, line, pos)
+	}
+}
+
+// escape 处理方向键、Home、End 和 Delete 的转义序列。
+func (e *LineEditor) escape(line []rune, pos int, historyIndex int) ([]rune, int, int) {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return line, pos, historyIndex
+	}
+	code, _, err := e.reader.ReadRune()
+	if err != nil {
+		return line, pos, historyIndex
+	}
+	if code >= '0' && code <= '9' {
+		if next, _, err := e.reader.ReadRune(); err != nil || next != '~' {
+			return line, pos, historyIndex
+		}
+	}
+	switch code {
+	case 'A': // ↑
+		if historyIndex > 0 {
+			historyIndex--
+			line = []rune(e.History[historyIndex])
+			pos = len(line)
+		}
+	case 'B': // ↓
+		if historyIndex < len(e.History)-1 {
+			historyIndex++
+			line = []rune(e.History[historyIndex])
+		} else {
+			historyIndex = len(e.History)
+			line = []rune{}
+		}
+		pos = len(line)
+	case 'C': // →
+		if pos < len(line) {
+			pos++
+		}
+	case 'D': // ←
+		if pos > 0 {
+			pos--
+		}
+	case 'H', '1', '7': // Home
+		pos = 0
+	case 'F', '4', '8': // End
+		pos = len(line)
+	case '3': // Delete
+		if pos < len(line) {
+			line = append(line[:pos], line[pos+1:]...)
+		}
+	}
+	return line, pos, historyIndex
+}
+
+// refresh 重新绘制提示符和输入内容，支持超过终端宽度时的自动换行。
+func (e *LineEditor) refresh(prompt string, line []rune, pos int) {
+	cols := terminalWidth(int(e.in.Fd()))
+	var b strings.Builder
+	if e.cursorRow > 0 {
+		fmt.Fprintf(&b, "\x1b[%dA", e.cursorRow)
+	}
+	b.WriteString("\r\x1b[J")
+	b.WriteString(prompt)
+	b.WriteString(string(line))
+	promptWidth := stringWidth([]rune(prompt))
+	total := promptWidth + stringWidth(line)
+	cursor := promptWidth + stringWidth(line[:pos])
+	if total > 0 && total%cols == 0 {
+		b.WriteString("\r\n")
+	}
+	endRow := total / cols
+	cursorRow := cursor / cols
+	if endRow > cursorRow {
+		fmt.Fprintf(&b, "\x1b[%dA", endRow-cursorRow)
+	}
+	b.WriteString("\r")
+	if col := cursor % cols; col > 0 {
+		fmt.Fprintf(&b, "\x1b[%dC", col)
+	}
+	e.cursorRow = cursorRow
+	io.WriteString(e.out, b.String())
+}
+
+// stringWidth 返回内容在终端中的显示宽度，中日韩字符和 Emoji 按两列计算。
+func stringWidth(runes []rune) int {
+	width := 0
+	for _, r := range runes {
+		width += runeWidth(r)
+	}
+	return width
+}
+
+func runeWidth(r rune) int {
+	switch {
+	case r < 0x1100:
+		return 1
+	case r <= 0x115F,
+		r >= 0x2E80 && r <= 0xA4CF,
+		r >= 0xAC00 && r <= 0xD7A3,
+		r >= 0xF900 && r <= 0xFAFF,
+		r >= 0xFE30 && r <= 0xFE4F,
+		r >= 0xFF00 && r <= 0xFF60,
+		r >= 0xFFE0 && r <= 0xFFE6,
+		r >= 0x1F300 && r <= 0x1FAFF,
+		r >= 0x20000 && r <= 0x3FFFD:
+		return 2
+	}
+	return 1
+}
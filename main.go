@@ -1,13 +1,16 @@
 package main
 
 import (
-	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"regexp"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/iuroc/gododo/biliqr"
 	"github.com/iuroc/gododo/dodo"
@@ -15,109 +18,152 @@ import (
 )
 
 func main() {
-	scanner := bufio.NewScanner(os.Stdin)
+	code := Run(os.Args[1:])
+	WaitHooks()
+	os.Exit(code)
+}
+
+// Interactive 交互模式，逐行读取文件路径或命令，读取到 EOF 或执行 :quit 时退出。
+func Interactive() int {
 	PrintHeader()
 	userInfo := GetUserInfo()
 	ClearTerminal()
 	PrintHeader()
-	for {
+	PrintAccount(userInfo)
+	repl := &REPL{UserInfo: userInfo, Format: "plain"}
+	reader := NewLineReader(os.Stdin, os.Stdout, repl.Complete)
+	for !repl.quit {
 		fmt.Printf("%s\n\n", strings.Repeat("-", 40))
-		fmt.Print("🚩 输入文件路径或拖拽文件到此处: ")
-		if !scanner.Scan() {
-			continue
-		}
-		path := TrimPathInput(scanner.Text())
-		work, err := dodo.NewUploadWork(path, userInfo.Token, userInfo.UID)
-		if os.IsNotExist(err) {
-			fmt.Println("❗ 错误: 文件不存在，请检查路径是否正确。")
+		line, err := reader.ReadLine("🚩 输入文件路径或拖拽文件到此处: ")
+		if err == ErrInterrupt {
 			continue
 		} else if err != nil {
-			fmt.Println("❗ 错误:", err)
-			continue
-		}
-		history, err := work.History()
-		if err != nil {
-			fmt.Println("❗ 错误:", err)
-			continue
-		}
-		if history.HasRecord {
-			fmt.Println("🎉 上传成功:", history.ResourceURL)
-			continue
-		}
-		if err = work.Upload(); err != nil {
-			fmt.Println("❗ 错误:", err)
-			continue
-		}
-		resourceURL, err := work.Record()
-		if err != nil {
-			fmt.Println("❗ 错误:", err)
-			continue
+			return ExitOK
 		}
-		fmt.Println("🎉 上传成功:", resourceURL)
-		fmt.Println()
+		repl.Exec(line)
 	}
+	return ExitOK
 }
 
 func PrintHeader() {
 	fmt.Print("DoDo 文件直链获取工具 [github.com/iuroc/gododo]\n\n")
 }
 
-// TrimPathInput 去除路径两端的特殊字符。
-func TrimPathInput(input string) string {
-	return regexp.MustCompile(`^[\s&'"]+|[\s&'"]+$|^file:///`).ReplaceAllString(input, "")
+// PrintAccount 输出当前登录的 DoDo 账号，获取资料失败时只输出 UID。
+func PrintAccount(userInfo *UserInfo) {
+	profile, err := dodo.GetUserProfile(userInfo.Token, userInfo.UID)
+	if err != nil || profile.NickName == "" {
+		fmt.Printf("👤 已登录: %s\n\n", userInfo.UID)
+		return
+	}
+	fmt.Printf("👤 已登录: %s (%s)\n\n", profile.NickName, userInfo.UID)
 }
 
 // GetUserInfo 获取用户信息，如果不存在，则要求用户扫码登录。
 func GetUserInfo() *UserInfo {
-	data, err := os.ReadFile("userInfo.json")
-	needQR := false
-	encryptedUserInfo := &UserInfo{}
-	var userInfo *UserInfo
+	userInfo, err := LoadUserInfo()
+	if err == nil {
+		return userInfo
+	}
+	userInfo, err = Login(os.Stdout)
+	if err != nil {
+		log.Fatalln("[Login]", err)
+	}
+	return userInfo
+}
+
+// ErrNotLoggedIn 未登录或登录已失效。
+var ErrNotLoggedIn = errors.New("未登录或登录已失效")
+
+// LoadUserInfo 读取已保存的用户信息并校验有效性，不会要求用户扫码。
+func LoadUserInfo() (*UserInfo, error) {
+	data, err := os.ReadFile(UserInfoPath())
 	if os.IsNotExist(err) {
-		needQR = true
+		return nil, ErrNotLoggedIn
 	} else if err != nil {
-		log.Fatalln("[os.ReadFile] userInfo 文件读取失败", err)
-	} else {
-		err = json.Unmarshal(data, encryptedUserInfo)
-		if err != nil {
-			needQR = true
-		} else {
-			userInfo, err = encryptedUserInfo.Decrypt()
-			needQR = err != nil || !userInfo.Check()
-		}
+		return nil, err
 	}
-	if needQR {
-		qr, info, err := biliqr.NewLoginQR(qrcode.Low)
+	encryptedUserInfo := &UserInfo{}
+	if err = json.Unmarshal(data, encryptedUserInfo); err != nil {
+		return nil, ErrNotLoggedIn
+	}
+	userInfo, err := encryptedUserInfo.Decrypt()
+	if err != nil || !userInfo.Check() {
+		return nil, ErrNotLoggedIn
+	}
+	return userInfo, nil
+}
+
+// Login 登录 DoDo 并保存用户信息，优先使用 cookies.txt，否则在 w 中输出二维码要求用户扫码。
+func Login(w io.Writer) (*UserInfo, error) {
+	if userInfo, err := LoginByCookiesFile(DataPath(CookiesFile)); err == nil {
+		return userInfo, nil
+	}
+	return LoginByQR(w)
+}
+
+// LoginByQR 在 w 中输出二维码，等待用户使用哔哩哔哩 APP 扫码登录。
+func LoginByQR(w io.Writer) (*UserInfo, error) {
+	qr, info, err := biliqr.NewLoginQR(qrcode.Low)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintln(w, "请使用哔哩哔哩 APP 扫描下方二维码:")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, qr.ToSmallString(false))
+	return pollQRLogin(context.Background(), info.OauthKey)
+}
+
+// QRLoginTimeout 等待扫码登录的最长时间，二维码通常在 3 分钟后失效。
+const QRLoginTimeout = 3 * time.Minute
+
+// pollQRLogin 每秒查询一次扫码状态，扫码确认后登录 DoDo 并保存用户信息。
+// 二维码失效、超过 [QRLoginTimeout] 或 ctx 取消时返回错误。
+func pollQRLogin(ctx context.Context, oauthKey string) (*UserInfo, error) {
+	ctx, cancel := context.WithTimeoutCause(ctx, QRLoginTimeout, errors.New("等待扫码超时"))
+	defer cancel()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		case <-ticker.C:
+		}
+		status, err := biliqr.GetThirdQRStatus(oauthKey)
 		if err != nil {
-			log.Fatalln("[biliqr.NewLoginQR] 创建二维码失败", err)
+			return nil, err
 		}
-		fmt.Println("请使用哔哩哔哩 APP 扫描下方二维码:")
-		fmt.Println()
-		fmt.Println(qr.ToSmallString(false))
-		for {
-			status, err := biliqr.GetThirdQRStatus(info.OauthKey)
-			if err != nil {
-				log.Fatalln("[biliqr.GetThirdQRStatus]", err)
-			}
-			if status.Success() {
-				token, uid, err := dodo.GetTokenAndUID(status.Data.TmpToken)
-				if err != nil {
-					log.Fatalln("dodo.GetTokenAndUID", err)
-				}
-				userInfo = &UserInfo{
-					Token: token,
-					UID:   uid,
-				}
-				encryptedUserInfo, err = userInfo.Encrypt()
-				if err != nil {
-					log.Fatalln("[userInfo.Encrypt]", err)
-				}
-				encryptedUserInfo.Save("userInfo.json")
-				return userInfo
-			}
+		if status.Expired() {
+			return nil, errors.New("二维码已失效，请重新登录")
+		}
+		if !status.Success() {
+			continue
+		}
+		token, uid, err := dodo.GetTokenAndUID(status.Data.TmpToken)
+		if err != nil {
+			return nil, err
 		}
+		userInfo := &UserInfo{Token: token, UID: uid}
+		return userInfo, userInfo.SaveEncrypted(UserInfoPath())
 	}
-	return userInfo
+}
+
+// LoginByCookiesFile 使用已有的 Bilibili Cookie 文件登录，支持 Netscape cookies.txt 和会话 JSON。
+func LoginByCookiesFile(path string) (*UserInfo, error) {
+	session, err := biliqr.LoadCookiesFile(path)
+	if err != nil {
+		return nil, err
+	}
+	token, uid, err := dodo.GetTokenAndUIDBySession(session)
+	if err != nil {
+		return nil, err
+	}
+	userInfo := &UserInfo{
+		Token: token,
+		UID:   uid,
+	}
+	return userInfo, userInfo.SaveEncrypted(UserInfoPath())
 }
 
 type UserInfo struct {
@@ -131,15 +177,24 @@ func (info *UserInfo) Check() bool {
 }
 
 // Save 以 JSON 格式保存 Token 和 UID 到文件。
-func (info *UserInfo) Save(path string) {
+func (info *UserInfo) Save(path string) error {
 	data, err := json.Marshal(info)
 	if err != nil {
-		log.Fatalln("[json.Marshal]", err)
+		return err
 	}
-	err = os.WriteFile(path, data, 0644)
+	return os.WriteFile(path, data, 0644)
+}
+
+// SaveEncrypted 加密 Token 和 UID 后保存到文件。
+func (info *UserInfo) SaveEncrypted(path string) error {
+	encryptedUserInfo, err := info.Encrypt()
 	if err != nil {
-		log.Fatalln("[os.WriteFile]", err)
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
 	}
+	return encryptedUserInfo.Save(path)
 }
 
 func (info *UserInfo) Encrypt() (*UserInfo, error) {
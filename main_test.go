@@ -2,10 +2,28 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"regexp"
 	"testing"
 )
 
+// TestMain 将数据目录设置为临时目录，避免测试读取或修改开发者的登录信息、上传历史和钩子配置。
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "gododo-test-")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Setenv("GODODO_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestParsePathInput(t *testing.T) {
 	str := `&& '1234''`
 	match := regexp.MustCompile(`^[\s&'"]+|[\s&'"]+$`)
@@ -25,3 +43,73 @@ func TestEncrypt(t *testing.T) {
 func TestGetUserInfo(t *testing.T) {
 	fmt.Printf("%#v", GetUserInfo())
 }
+
+func TestRunUsage(t *testing.T) {
+	if code := Run([]string{"nope"}); code != ExitUsage {
+		t.Fatal(code)
+	}
+	if code := Run([]string{"upload"}); code != ExitUsage {
+		t.Fatal(code)
+	}
+	if code := Run([]string{"help"}); code != ExitOK {
+		t.Fatal(code)
+	}
+}
+
+func TestSaveLinkQR(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "qr")
+	path, err := SaveLinkQR(&UploadResult{MD5: "abc", URL: "https://files.imdodo.com/dodo/abc.txt"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); err != nil || filepath.Dir(path) != dir {
+		t.Fatal(path, err)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken.txt" {
+			// 声明的长度大于实际写入的内容，客户端读取时会出错。
+			w.Header().Set("Content-Length", "100")
+		}
+		io.WriteString(w, "hello")
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+	out := filepath.Join(dir, "a.txt")
+	if err := Fetch(server.URL+"/a.txt", out); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fetch(server.URL+"/broken.txt", out); err == nil {
+		t.Fatal("expected error")
+	}
+	if data, _ := os.ReadFile(out); string(data) != "hello" {
+		t.Fatalf("%q", data)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("partial file left: %v", entries)
+	}
+}
+
+func TestCheckProfile(t *testing.T) {
+	for _, name := range []string{"", "default", "work", "a.b", "work-2"} {
+		if err := CheckProfile(name); err != nil {
+			t.Fatal(name, err)
+		}
+	}
+	for _, name := range []string{"..", "../x", "a/b", `a\b`, "a..b"} {
+		if err := CheckProfile(name); err == nil {
+			t.Fatal(name)
+		}
+	}
+}
+
+func TestSaveEncryptedError(t *testing.T) {
+	t.Setenv("GODODO_HOME", t.TempDir())
+	// 目标路径是目录，写入失败时应当返回错误，而不是退出进程。
+	path := t.TempDir()
+	if err := (&UserInfo{Token: "t", UID: "1"}).SaveEncrypted(path); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestEntry 清单中单个文件的记录。
+type ManifestEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	MD5     string    `json:"md5"`
+	URL     string    `json:"url"`
+}
+
+// Manifest 已上传文件的清单，以绝对路径为键。
+//
+// 文件的大小和修改时间与清单一致时，可以直接使用记录的直链，无需重新计算 MD5 和上传。
+type Manifest struct {
+	path    string
+	Entries map[string]*ManifestEntry `json:"entries"`
+}
+
+// LoadManifest 读取清单文件，文件不存在时返回空清单。
+func LoadManifest(path string) (*Manifest, error) {
+	manifest := &Manifest{path: path, Entries: map[string]*ManifestEntry{}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, manifest); err != nil {
+		return nil, err
+	}
+	if manifest.Entries == nil {
+		manifest.Entries = map[string]*ManifestEntry{}
+	}
+	return manifest, nil
+}
+
+// Lookup 返回与文件当前大小和修改时间一致的记录。
+func (m *Manifest) Lookup(path string, stat os.FileInfo) (*ManifestEntry, bool) {
+	entry, ok := m.Entries[path]
+	if !ok || entry.Size != stat.Size() || !entry.ModTime.Equal(stat.ModTime()) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// Put 记录上传结果。
+func (m *Manifest) Put(result *UploadResult, stat os.FileInfo) {
+	m.Entries[result.Path] = &ManifestEntry{
+		Path:    result.Path,
+		Size:    stat.Size(),
+		ModTime: stat.ModTime(),
+		MD5:     result.MD5,
+		URL:     result.URL,
+	}
+}
+
+// Sorted 返回按路径排序的全部记录。
+func (m *Manifest) Sorted() []*ManifestEntry {
+	entries := []*ManifestEntry{}
+	for _, entry := range m.Entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// Save 保存清单到读取时的路径，先写入临时文件再替换，避免中断时留下不完整的文件。
+func (m *Manifest) Save() error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return err
+	}
+	temp := m.path + ".tmp"
+	if err = os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, m.path)
+}
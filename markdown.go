@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// LocalRef 文档中引用本地文件的位置。
+type LocalRef struct {
+	// 引用在文档中的字节范围。
+	Start, End int
+	// 文档中的原始引用。
+	Target string
+	// 本地文件的绝对路径。
+	Path string
+	// 引用中的查询参数和锚点，例如 #page=2，替换时保留。
+	Suffix string
+}
+
+var (
+	markdownInlineLink = regexp.MustCompile(`!?\[[^\]]*\]\(\s*(<[^>\n]+>|[^)\s]+)(?:\s+(?:"[^"]*"|'[^']*'|\([^)]*\)))?\s*\)`)
+	markdownDefinition = regexp.MustCompile(`^ {0,3}\[[^\]]+\]:\s*(<[^>\n]+>|\S+)`)
+	markdownHTMLAttr   = regexp.MustCompile(`\s(?:src|href)\s*=\s*["']([^"']+)["']`)
+	markdownFence      = regexp.MustCompile("^ {0,3}(```|~~~)")
+	markdownCodeSpan   = regexp.MustCompile("`+[^`]*`+")
+	urlScheme          = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9+.-]+:`)
+)
+
+// FindMarkdownRefs 查找 Markdown 中引用的本地图片和链接，路径相对于 baseDir。
+//
+// 支持行内链接和图片、引用式链接定义以及 HTML 标签的 src 和 href 属性，代码块和行内代码中的内容会被忽略。
+func FindMarkdownRefs(doc []byte, baseDir string) []LocalRef {
+	refs := []LocalRef{}
+	inFence := false
+	offset := 0
+	for _, line := range strings.SplitAfter(string(doc), "\n") {
+		lineOffset := offset
+		offset += len(line)
+		if markdownFence.MatchString(line) {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		masked := markdownCodeSpan.ReplaceAllStringFunc(line, func(code string) string {
+			return strings.Repeat(" ", len(code))
+		})
+		matches := markdownInlineLink.FindAllStringSubmatchIndex(masked, -1)
+		matches = append(matches, markdownDefinition.FindAllStringSubmatchIndex(masked, -1)...)
+		ranges := submatchRanges(matches)
+		ranges = append(ranges, TagAttrRanges(masked, markdownHTMLAttr)...)
+		for _, match := range ranges {
+			target := line[match[0]:match[1]]
+			path, suffix, ok := ResolveLocalTarget(strings.Trim(target, "<>"), baseDir)
+			if !ok || isMarkdownFile(path) {
+				continue
+			}
+			refs = append(refs, LocalRef{
+				Start:  lineOffset + match[0],
+				End:    lineOffset + match[1],
+				Target: target,
+				Path:   path,
+				Suffix: suffix,
+			})
+		}
+	}
+	sortRefs(refs)
+	return refs
+}
+
+// sortRefs 按照在文档中的位置排序。
+func sortRefs(refs []LocalRef) {
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].Start < refs[j].Start
+	})
+}
+
+// ResolveLocalTarget 将文档中的引用解析为存在的本地文件，返回绝对路径和需要保留的查询参数与锚点。
+//
+// 带有协议的地址、协议相对地址、页内锚点以及不存在的文件返回 ok 为 false。
+func ResolveLocalTarget(target string, baseDir string) (path string, suffix string, ok bool) {
+	target = strings.TrimSpace(target)
+	// 单个字母的协议视为 Windows 盘符。
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "//") || urlScheme.MatchString(target) {
+		return "", "", false
+	}
+	if index := strings.IndexAny(target, "?#"); index >= 0 {
+		target, suffix = target[:index], target[index:]
+	}
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+	path = filepath.FromSlash(target)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(baseDir, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", "", false
+	}
+	stat, err := os.Stat(path)
+	if err != nil || !stat.Mode().IsRegular() {
+		return "", "", false
+	}
+	return path, suffix, true
+}
+
+// SplitRefsInDir 将引用分为位于目录 dir 中的文件和目录之外的文件，符号链接按实际指向的文件判断。
+func SplitRefsInDir(refs []LocalRef, dir string) (inside []LocalRef, outside []LocalRef) {
+	dir, err := filepath.Abs(dir)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	for _, ref := range refs {
+		path, pathErr := filepath.EvalSymlinks(ref.Path)
+		if err == nil && pathErr == nil && isInsideDir(path, dir) {
+			inside = append(inside, ref)
+		} else {
+			outside = append(outside, ref)
+		}
+	}
+	return inside, outside
+}
+
+// isInsideDir 判断绝对路径 path 是否位于目录 dir 中。
+func isInsideDir(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && !filepath.IsAbs(rel) && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func isMarkdownFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".md" || ext == ".markdown"
+}
+
+// ReplaceRefs 将文档中的引用替换为 urls 中对应路径的直链，并保留查询参数与锚点。
+func ReplaceRefs(doc []byte, refs []LocalRef, urls map[string]string) []byte {
+	var b bytes.Buffer
+	last := 0
+	for _, ref := range refs {
+		resourceURL, ok := urls[ref.Path]
+		if !ok || ref.Start < last {
+			continue
+		}
+		b.Write(doc[last:ref.Start])
+		b.WriteString(resourceURL + ref.Suffix)
+		last = ref.End
+	}
+	b.Write(doc[last:])
+	return b.Bytes()
+}
+
+// RefMapping 本地文件与直链的对应关系，用于输出映射报告。
+type RefMapping struct {
+	Target string `json:"target"`
+	Path   string `json:"path"`
+	URL    string `json:"url"`
+	MD5    string `json:"md5"`
+	Size   int64  `json:"size"`
+}
+
+// UploadRefs 上传全部引用的文件，返回路径与直链的对应关系和映射报告，失败的文件输出到标准错误。
+func UploadRefs(refs []LocalRef, uploader *Uploader) (urls map[string]string, mappings []RefMapping, failed int) {
+	urls = map[string]string{}
+	mappings = []RefMapping{}
+	for _, ref := range refs {
+		if _, ok := urls[ref.Path]; ok {
+			continue
+		}
+		result, err := uploader.Upload(ref.Path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", ref.Target, err)
+			failed++
+			continue
+		}
+		urls[ref.Path] = result.URL
+		mappings = append(mappings, RefMapping{
+			Target: ref.Target,
+			Path:   ref.Path,
+			URL:    result.URL,
+			MD5:    result.MD5,
+			Size:   result.Size,
+		})
+	}
+	return urls, mappings, failed
+}
+
+// PrintLineDiff 逐行比较替换前后的文档，输出发生变化的行。
+func PrintLineDiff(w io.Writer, name string, before []byte, after []byte) {
+	oldLines := strings.Split(string(before), "\n")
+	newLines := strings.Split(string(after), "\n")
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", name, name)
+	for index := range oldLines {
+		if index >= len(newLines) || oldLines[index] == newLines[index] {
+			continue
+		}
+		fmt.Fprintf(w, "@@ %d @@\n-%s\n+%s\n", index+1, oldLines[index], newLines[index])
+	}
+}
+
+// WriteMappingReport 以 JSON 格式保存映射报告。
+func WriteMappingReport(path string, mappings []RefMapping) error {
+	data, err := json.MarshalIndent(mappings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+func cmdMarkdown(args []string) int {
+	if len(args) == 0 || args[0] != "rewrite" {
+		fmt.Fprintln(os.Stderr, "用法: gododo md rewrite [-o path] [-i] [--dry-run] [--report path] [--allow-outside] doc.md")
+		return ExitUsage
+	}
+	flags := newFlagSet("md")
+	out := flags.String("o", "", "保存替换后的文档，默认输出到标准输出")
+	inPlace := flags.Bool("i", false, "直接修改原文档")
+	dryRun := flags.Bool("dry-run", false, "不上传文件，只输出将要发生的修改和映射报告")
+	report := flags.String("report", "", "以 JSON 格式保存本地文件与直链的映射报告")
+	allowOutside := flags.Bool("allow-outside", false, "允许上传文档所在目录之外的文件，例如绝对路径和 ../ 引用的文件")
+	if code := parseFlags(flags, args[1:]); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 1 || (*inPlace && *out != "") {
+		flags.Usage()
+		return ExitUsage
+	}
+	docPath := flags.Arg(0)
+	doc, err := os.ReadFile(docPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		if os.IsNotExist(err) {
+			return ExitNotFound
+		}
+		return ExitError
+	}
+	uploader := &Uploader{DryRun: *dryRun}
+	if !*dryRun {
+		userInfo, code := requireUserInfo()
+		if userInfo == nil {
+			return code
+		}
+		uploader.UserInfo = userInfo
+	}
+	refs := FindMarkdownRefs(doc, filepath.Dir(docPath))
+	if !*allowOutside {
+		// 防止文档中的 /home/u/.ssh/id_rsa 或 ../../.aws/credentials 等引用将私密文件上传到公开的直链。
+		var outside []LocalRef
+		refs, outside = SplitRefsInDir(refs, filepath.Dir(docPath))
+		for _, ref := range outside {
+			fmt.Fprintf(os.Stderr, "⚠️ 跳过文档目录之外的文件，需要上传时请使用 --allow-outside: %s\n", ref.Target)
+		}
+	}
+	urls, mappings, failed := UploadRefs(refs, uploader)
+	rewritten := ReplaceRefs(doc, refs, urls)
+	for _, mapping := range mappings {
+		fmt.Fprintf(os.Stderr, "%s -> %s\n", mapping.Target, mapping.URL)
+	}
+	if *report != "" {
+		if err = WriteMappingReport(*report, mappings); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+	}
+	switch {
+	case *dryRun:
+		PrintLineDiff(os.Stdout, docPath, doc, rewritten)
+	case *inPlace:
+		err = os.WriteFile(docPath, rewritten, 0644)
+	case *out != "":
+		err = os.WriteFile(*out, rewritten, 0644)
+	default:
+		_, err = os.Stdout.Write(rewritten)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	if failed > 0 {
+		return ExitUpload
+	}
+	return ExitOK
+}
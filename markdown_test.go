@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRewriteMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "img"), 0755)
+	os.WriteFile(filepath.Join(dir, "img", "x.png"), []byte("png"), 0644)
+	os.WriteFile(filepath.Join(dir, "a b.pdf"), []byte("pdf"), 0644)
+	doc := "![x](./img/x.png \"t\") [pdf](<a b.pdf>) [web](https://x.com/a.png) `![c](img/x.png)`\n" +
+		"```\n![f](img/x.png)\n```\n" +
+		"[ref]: img/x.png#frag\n" +
+		"<img src=\"a%20b.pdf\">\n"
+	refs := FindMarkdownRefs([]byte(doc), dir)
+	if len(refs) != 4 {
+		t.Fatalf("%#v", refs)
+	}
+	urls := map[string]string{
+		filepath.Join(dir, "img", "x.png"): "https://files.imdodo.com/dodo/x.png",
+		filepath.Join(dir, "a b.pdf"):      "https://files.imdodo.com/dodo/ab.pdf",
+	}
+	want := "![x](https://files.imdodo.com/dodo/x.png \"t\") [pdf](https://files.imdodo.com/dodo/ab.pdf) [web](https://x.com/a.png) `![c](img/x.png)`\n" +
+		"```\n![f](img/x.png)\n```\n" +
+		"[ref]: https://files.imdodo.com/dodo/x.png#frag\n" +
+		"<img src=\"https://files.imdodo.com/dodo/ab.pdf\">\n"
+	if got := string(ReplaceRefs([]byte(doc), refs, urls)); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSplitRefsInDir(t *testing.T) {
+	root := t.TempDir()
+	docDir := filepath.Join(root, "docs")
+	os.MkdirAll(filepath.Join(docDir, "..img"), 0755)
+	os.WriteFile(filepath.Join(docDir, "..img", "a.png"), []byte("a"), 0644)
+	os.WriteFile(filepath.Join(root, "secret"), []byte("s"), 0644)
+	doc := "![a](..img/a.png) ![s](../secret) ![abs](" + filepath.ToSlash(filepath.Join(root, "secret")) + ")\n"
+	refs := FindMarkdownRefs([]byte(doc), docDir)
+	if len(refs) != 3 {
+		t.Fatalf("%#v", refs)
+	}
+	inside, outside := SplitRefsInDir(refs, docDir)
+	if len(inside) != 1 || inside[0].Target != "..img/a.png" || len(outside) != 2 {
+		t.Fatalf("inside = %#v, outside = %#v", inside, outside)
+	}
+}
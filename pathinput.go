@@ -0,0 +1,210 @@
+package main
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ParsePathInput 解析输入的一行内容，返回其中的全部文件路径。
+//
+// 如果整行去除两端的特殊字符后就是一个存在的文件，则视为单个路径，兼容未加引号的含空格路径。
+func ParsePathInput(line string) []string {
+	path := TrimPathInput(line)
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return []string{path}
+	}
+	return SplitPathInput(line)
+}
+
+// TrimPathInput 去除路径两端的特殊字符。
+func TrimPathInput(input string) string {
+	return regexp.MustCompile(`^[\s&'"]+|[\s&'"]+$|^file:///`).ReplaceAllString(input, "")
+}
+
+// escapable 在引号外可以被反斜杠转义的字符。
+//
+// 反斜杠本身不在其中，以便保留未加引号的 Windows 路径，例如 C:\Users\a.txt。
+const escapable = " \t'\"()[]{}&;|<>*?$!#~`"
+
+// SplitPathInput 按照终端拖拽文件时的格式，将一行内容拆分为多个路径。支持以下格式：
+//
+//	PowerShell:        & 'C:\a b.txt' 'C:\c.txt'
+//	cmd:               "C:\a b.txt" "C:\c.txt"
+//	bash/zsh/macOS:    /home/a\ b.txt /home/c\ \(1\).txt
+//	GNOME/KDE:         file:///home/a%20b.txt file:///home/c.txt
+func SplitPathInput(line string) []string {
+	paths := []string{}
+	var current strings.Builder
+	inToken := false
+	runes := []rune(line)
+	flush := func() {
+		if inToken {
+			paths = append(paths, decodeFileURI(current.String()))
+		}
+		current.Reset()
+		inToken = false
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == ' ' || r == '\t' || r == '\r' || r == '\n':
+			flush()
+		case r == '&' && !inToken:
+			// PowerShell 的调用运算符。
+		case r == '\'':
+			inToken = true
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					// PowerShell 使用两个单引号表示一个单引号。
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						current.WriteRune('\'')
+						i++
+						continue
+					}
+					break
+				}
+				current.WriteRune(runes[i])
+			}
+		case r == '"':
+			inToken = true
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\\' && i+1 < len(runes) && runes[i+1] == '"' {
+					current.WriteRune('"')
+					i++
+					continue
+				}
+				if runes[i] == '"' {
+					break
+				}
+				current.WriteRune(runes[i])
+			}
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(escapable, runes[i+1]):
+			inToken = true
+			current.WriteRune(runes[i+1])
+			i++
+		default:
+			inToken = true
+			current.WriteRune(r)
+		}
+	}
+	flush()
+	return paths
+}
+
+// decodeFileURI 将 file:// URI 转换为本地路径，其他内容原样返回。
+func decodeFileURI(path string) string {
+	if !strings.HasPrefix(path, "file://") {
+		return path
+	}
+	u, err := url.Parse(path)
+	if err != nil {
+		return path
+	}
+	decoded := u.Path
+	// Windows 路径 file:///C:/a.txt
+	if regexp.MustCompile(`^/[A-Za-z]:/`).MatchString(decoded) {
+		decoded = decoded[1:]
+	}
+	return decoded
+}
+
+// CompletePath 补全 head 中最后一个路径，返回补全后的内容和全部候选项。
+//
+// head 为光标前的输入内容，路径的格式与 [SplitPathInput] 相同。
+func CompletePath(head string) (string, []string) {
+	partial, quote := lastPathToken(head)
+	if strings.HasPrefix(partial, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			partial = home + partial[1:]
+		}
+	}
+	dir, base := filepath.Split(partial)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return head, nil
+	}
+	candidates := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (base == "" && strings.HasPrefix(name, ".")) {
+			continue
+		}
+		if stat, err := os.Stat(filepath.Join(readDir, name)); err == nil && stat.IsDir() {
+			name += string(filepath.Separator)
+		}
+		candidates = append(candidates, name)
+	}
+	if len(candidates) == 0 {
+		return head, nil
+	}
+	suffix := strings.TrimPrefix(commonPrefix(candidates), base)
+	if quote == 0 {
+		suffix = escapePath(suffix)
+	}
+	return head + suffix, candidates
+}
+
+// lastPathToken 返回 head 中最后一个未完成的路径，以及它所在的引号，不在引号中时为 0。
+func lastPathToken(head string) (string, rune) {
+	var current strings.Builder
+	var quote rune
+	runes := []rune(head)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == ' ' || r == '\t':
+			current.Reset()
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '&' && current.Len() == 0:
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(escapable, runes[i+1]):
+			current.WriteRune(runes[i+1])
+			i++
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return current.String(), quote
+}
+
+// escapePath 使用反斜杠转义路径中的特殊字符。
+func escapePath(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(escapable, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// commonPrefix 返回全部字符串的最长公共前缀。
+func commonPrefix(items []string) string {
+	prefix := []rune(items[0])
+	for _, item := range items[1:] {
+		runes := []rune(item)
+		n := 0
+		for n < len(prefix) && n < len(runes) && prefix[n] == runes[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	return string(prefix)
+}
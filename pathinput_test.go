@@ -0,0 +1,48 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitPathInput(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []string
+	}{
+		{`& 'C:\a b.txt' 'C:\c.txt'`, []string{`C:\a b.txt`, `C:\c.txt`}},
+		{`& 'C:\it''s.txt'`, []string{`C:\it's.txt`}},
+		{`"C:\a b.txt" "C:\c.txt"`, []string{`C:\a b.txt`, `C:\c.txt`}},
+		{`C:\Users\a.txt D:\b.txt`, []string{`C:\Users\a.txt`, `D:\b.txt`}},
+		{`/home/u/a\ b.txt /home/u/c\ \(1\).txt `, []string{`/home/u/a b.txt`, `/home/u/c (1).txt`}},
+		{`'/home/u/a b.txt' '/home/u/c.txt'`, []string{`/home/u/a b.txt`, `/home/u/c.txt`}},
+		{`file:///home/u/a%20b.txt file:///home/u/%E4%B8%AD.txt`, []string{`/home/u/a b.txt`, `/home/u/中.txt`}},
+		{`file:///C:/a%20b.txt`, []string{`C:/a b.txt`}},
+		{"  \t ", []string{}},
+	}
+	for _, test := range tests {
+		got := SplitPathInput(test.input)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SplitPathInput(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
+
+func TestCompletePath(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"my file.txt", "my photo.png"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	head, candidates := CompletePath(dir + "/my\\ f")
+	if head != dir+"/my\\ file.txt" || len(candidates) != 1 {
+		t.Fatal(head, candidates)
+	}
+	head, candidates = CompletePath("'" + dir + "/m")
+	if head != "'"+dir+"/my " || len(candidates) != 2 {
+		t.Fatal(head, candidates)
+	}
+}
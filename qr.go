@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/skip2/go-qrcode"
+)
+
+// PrintLinkQR 在终端输出直链的二维码，方便使用手机扫码下载。
+func PrintLinkQR(w io.Writer, link string) error {
+	qr, err := qrcode.New(link, qrcode.Low)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, qr.ToSmallString(false))
+	return nil
+}
+
+// SaveLinkQR 将直链的二维码保存到目录 dir 中的 <md5>.qr.png，返回文件路径。
+func SaveLinkQR(result *UploadResult, dir string) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, result.MD5+".qr.png")
+	if err := qrcode.WriteFile(result.URL, qrcode.Medium, 256, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// QueueFile 后台上传队列文件名，位于 [DataPath] 指定的目录。
+const QueueFile = "queue.json"
+
+// 上传任务的状态。
+const (
+	JobPending   = "pending"
+	JobUploading = "uploading"
+	JobDone      = "done"
+	JobFailed    = "failed"
+)
+
+// Job 后台上传队列中的任务。
+type Job struct {
+	ID       int           `json:"id"`
+	Path     string        `json:"path"`
+	State    string        `json:"state"`
+	Attempts int           `json:"attempts"`
+	Error    string        `json:"error,omitempty"`
+	Added    time.Time     `json:"added"`
+	NextTry  time.Time     `json:"nextTry"`
+	Result   *UploadResult `json:"result,omitempty"`
+	// 上传进度只保存在内存中，用于 status 命令，不会写入队列文件。
+	Sent  int64 `json:"sent,omitempty"`
+	Total int64 `json:"total,omitempty"`
+}
+
+// Queue 持久化的上传队列，每次修改后保存到文件，可以在多个 goroutine 中同时使用。
+type Queue struct {
+	path   string
+	mu     sync.Mutex
+	NextID int    `json:"nextId"`
+	Jobs   []*Job `json:"jobs"`
+}
+
+// LoadQueue 读取队列文件，文件不存在时返回空队列。上次退出时正在上传的任务会重新排队。
+func LoadQueue(path string) (*Queue, error) {
+	queue := &Queue{path: path, NextID: 1}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		if err = json.Unmarshal(data, queue); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	for _, job := range queue.Jobs {
+		if job.State == JobUploading {
+			job.State = JobPending
+		}
+	}
+	return queue, nil
+}
+
+// save 写入临时文件后替换队列文件，避免退出时留下不完整的文件。
+func (q *Queue) save() error {
+	jobs := copyJobs(q.Jobs)
+	for _, job := range jobs {
+		job.Sent, job.Total = 0, 0
+	}
+	data, err := json.MarshalIndent(&Queue{NextID: q.NextID, Jobs: jobs}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+		return err
+	}
+	temp := q.path + ".tmp"
+	if err = os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, q.path)
+}
+
+// Add 添加等待上传的任务并保存队列。
+func (q *Queue) Add(paths []string) ([]*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := []*Job{}
+	for _, path := range paths {
+		job := &Job{ID: q.NextID, Path: path, State: JobPending, Added: time.Now()}
+		q.NextID++
+		q.Jobs = append(q.Jobs, job)
+		jobs = append(jobs, job)
+	}
+	return copyJobs(jobs), q.save()
+}
+
+// Snapshot 返回全部任务的副本。
+func (q *Queue) Snapshot() []*Job {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return copyJobs(q.Jobs)
+}
+
+func copyJobs(jobs []*Job) []*Job {
+	result := make([]*Job, len(jobs))
+	for i, job := range jobs {
+		copied := *job
+		result[i] = &copied
+	}
+	return result
+}
+
+// Next 将下一个到达重试时间的任务标记为正在上传并返回，没有任务时返回 nil。
+func (q *Queue) Next(now time.Time) (*Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.Jobs {
+		if job.State == JobPending && !job.NextTry.After(now) {
+			job.State = JobUploading
+			job.Attempts++
+			job.Sent, job.Total = 0, 0
+			copied := *job
+			return &copied, q.save()
+		}
+	}
+	return nil, nil
+}
+
+// NextTry 返回等待中的任务最早的重试时间，没有等待中的任务时 ok 为 false。
+func (q *Queue) NextTry() (next time.Time, ok bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, job := range q.Jobs {
+		if job.State == JobPending && (!ok || job.NextTry.Before(next)) {
+			next, ok = job.NextTry, true
+		}
+	}
+	return next, ok
+}
+
+// Progress 更新任务的上传进度，不保存队列。
+func (q *Queue) Progress(id int, sent int64, total int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job := q.find(id); job != nil {
+		job.Sent, job.Total = sent, total
+	}
+}
+
+// Finish 记录任务的上传结果。失败时在 retryAt 重试，retryAt 为零值时不再重试。
+func (q *Queue) Finish(id int, result *UploadResult, err error, retryAt time.Time) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job := q.find(id)
+	if job == nil {
+		return nil
+	}
+	job.Sent, job.Total = 0, 0
+	switch {
+	case err == nil:
+		job.State, job.Result, job.Error = JobDone, result, ""
+		job.NextTry = time.Time{}
+	case retryAt.IsZero():
+		job.State, job.Error = JobFailed, err.Error()
+	default:
+		job.State, job.Error, job.NextTry = JobPending, err.Error(), retryAt
+	}
+	return q.save()
+}
+
+// Retry 将失败的任务重新排队，返回重新排队的任务数。
+func (q *Queue) Retry() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	count := 0
+	for _, job := range q.Jobs {
+		if job.State == JobFailed {
+			job.State, job.Attempts, job.NextTry = JobPending, 0, time.Time{}
+			count++
+		}
+	}
+	return count, q.save()
+}
+
+// Clear 删除已完成的任务，返回删除的任务数。
+func (q *Queue) Clear() (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	jobs := []*Job{}
+	for _, job := range q.Jobs {
+		if job.State != JobDone {
+			jobs = append(jobs, job)
+		}
+	}
+	count := len(q.Jobs) - len(jobs)
+	q.Jobs = jobs
+	return count, q.save()
+}
+
+func (q *Queue) find(id int) *Job {
+	for _, job := range q.Jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// REPL 交互模式的会话状态。输入以冒号开头时作为命令执行，否则作为文件路径上传。
+type REPL struct {
+	UserInfo *UserInfo
+	// 本次会话的上传结果。
+	Results []*UploadResult
+	// 最近一次上传失败的路径，用于 :retry。
+	Failed []string
+	// 直链输出格式，参见 [Formats]。
+	Format string
+	// 上传成功后在终端输出直链的二维码。
+	QR bool
+	// 上传成功后将直链的二维码保存为 PNG。
+	QRPNG bool
+	quit  bool
+}
+
+type replCommand struct {
+	Name    string
+	Usage   string
+	Summary string
+	Run     func(r *REPL, args []string)
+}
+
+var replCommands []replCommand
+
+func init() {
+	replCommands = []replCommand{
+		{"help", ":help", "输出命令列表", (*REPL).cmdHelp},
+		{"history", ":history", "输出本次会话的上传记录", (*REPL).cmdHistory},
+		{"copy", ":copy N", "重新输出第 N 条上传记录的直链", (*REPL).cmdCopy},
+		{"retry", ":retry", "重新上传最近一次失败的文件", (*REPL).cmdRetry},
+		{"format", ":format [name|template]", "设置直链输出格式，可选 " + strings.Join(Formats, "、"), (*REPL).cmdFormat},
+		{"qr", ":qr [png]", "开关终端二维码输出，png 表示开关 PNG 保存", (*REPL).cmdQR},
+		{"login", ":login", "重新扫码登录当前账号", (*REPL).cmdLogin},
+		{"switch", ":switch [profile]", "切换账号，没有参数时列出全部账号", (*REPL).cmdSwitch},
+		{"quit", ":quit", "退出", (*REPL).cmdQuit},
+	}
+}
+
+// Exec 执行一行输入。
+func (r *REPL) Exec(line string) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	if !strings.HasPrefix(line, ":") {
+		r.Upload(ParsePathInput(line))
+		return
+	}
+	fields := strings.Fields(line[1:])
+	if len(fields) == 0 {
+		return
+	}
+	name := fields[0]
+	if name == "q" || name == "exit" {
+		name = "quit"
+	}
+	for _, command := range replCommands {
+		if command.Name == name {
+			command.Run(r, fields[1:])
+			return
+		}
+	}
+	fmt.Println("❗ 错误: 未知命令", line, "，输入 :help 查看全部命令。")
+}
+
+// Upload 依次上传文件并输出直链，记录失败的路径。
+func (r *REPL) Upload(paths []string) {
+	failed := []string{}
+	for _, path := range paths {
+		if len(paths) > 1 {
+			fmt.Println("📄", path)
+		}
+		result, err := UploadFile(path, r.UserInfo)
+		if os.IsNotExist(err) {
+			fmt.Println("❗ 错误: 文件不存在，请检查路径是否正确。")
+			failed = append(failed, path)
+			continue
+		} else if err != nil {
+			fmt.Println("❗ 错误:", err)
+			failed = append(failed, path)
+			continue
+		}
+		r.Results = append(r.Results, result)
+		r.printResult(result)
+	}
+	if len(failed) > 0 {
+		r.Failed = failed
+	}
+	if len(paths) > 0 {
+		fmt.Println()
+	}
+}
+
+func (r *REPL) printResult(result *UploadResult) {
+	link, err := FormatLink(result, r.Format)
+	if err != nil {
+		link = result.URL
+	}
+	fmt.Println("🎉 上传成功:", link)
+	if r.QR {
+		if err := PrintLinkQR(os.Stdout, result.URL); err != nil {
+			fmt.Println("❗ 错误: 二维码输出失败:", err)
+		}
+	}
+	if r.QRPNG {
+		path, err := SaveLinkQR(result, DataPath(""))
+		if err != nil {
+			fmt.Println("❗ 错误: 二维码保存失败:", err)
+			return
+		}
+		fmt.Println("🖼️ 二维码已保存:", path)
+	}
+}
+
+// Complete 补全命令、命令参数或文件路径。
+func (r *REPL) Complete(head string) (string, []string) {
+	if !strings.HasPrefix(head, ":") {
+		return CompletePath(head)
+	}
+	name, arg, hasArg := strings.Cut(head[1:], " ")
+	options := []string{}
+	prefix := name
+	if !hasArg {
+		for _, command := range replCommands {
+			options = append(options, command.Name)
+		}
+	} else {
+		prefix = arg
+		switch name {
+		case "format":
+			options = Formats
+		case "qr":
+			options = []string{"png"}
+		case "switch":
+			options = ListProfiles()
+		}
+	}
+	candidates := []string{}
+	for _, option := range options {
+		if strings.HasPrefix(option, prefix) {
+			candidates = append(candidates, option)
+		}
+	}
+	if len(candidates) == 0 {
+		return head, nil
+	}
+	completed := head + strings.TrimPrefix(commonPrefix(candidates), prefix)
+	if len(candidates) == 1 && !hasArg {
+		completed += " "
+	}
+	return completed, candidates
+}
+
+func (r *REPL) cmdHelp(args []string) {
+	for _, command := range replCommands {
+		fmt.Printf("  %-28s %s\n", command.Usage, command.Summary)
+	}
+	fmt.Println()
+}
+
+func (r *REPL) cmdHistory(args []string) {
+	if len(r.Results) == 0 {
+		fmt.Println("本次会话还没有上传记录。")
+	}
+	for index, result := range r.Results {
+		link, _ := FormatLink(result, r.Format)
+		fmt.Printf("%d. %s  %s\n", index+1, result.Base, link)
+	}
+	fmt.Println()
+}
+
+func (r *REPL) cmdCopy(args []string) {
+	if len(args) != 1 {
+		fmt.Println("用法: :copy N")
+		return
+	}
+	index, err := strconv.Atoi(args[0])
+	if err != nil || index < 1 || index > len(r.Results) {
+		fmt.Println("❗ 错误: 没有第", args[0], "条上传记录。")
+		return
+	}
+	r.printResult(r.Results[index-1])
+	fmt.Println()
+}
+
+func (r *REPL) cmdRetry(args []string) {
+	if len(r.Failed) == 0 {
+		fmt.Println("没有需要重新上传的文件。")
+		return
+	}
+	paths := r.Failed
+	r.Failed = nil
+	r.Upload(paths)
+}
+
+func (r *REPL) cmdFormat(args []string) {
+	if len(args) == 0 {
+		fmt.Println("当前输出格式:", r.Format)
+		return
+	}
+	format := strings.Join(args, " ")
+	if _, err := FormatLink(&UploadResult{}, format); err != nil {
+		fmt.Println("❗ 错误:", err)
+		return
+	}
+	r.Format = format
+	fmt.Println("输出格式已设置为", r.Format)
+}
+
+func (r *REPL) cmdQR(args []string) {
+	state := map[bool]string{true: "开启", false: "关闭"}
+	if len(args) > 0 && args[0] == "png" {
+		r.QRPNG = !r.QRPNG
+		fmt.Println("二维码 PNG 保存已" + state[r.QRPNG])
+		return
+	}
+	r.QR = !r.QR
+	fmt.Println("终端二维码输出已" + state[r.QR])
+}
+
+func (r *REPL) cmdLogin(args []string) {
+	userInfo, err := LoginByQR(os.Stdout)
+	if err != nil {
+		fmt.Println("❗ 错误:", err)
+		return
+	}
+	r.UserInfo = userInfo
+	PrintAccount(userInfo)
+}
+
+func (r *REPL) cmdSwitch(args []string) {
+	if len(args) == 0 {
+		current := Profile
+		if current == "" {
+			current = "default"
+		}
+		for _, profile := range ListProfiles() {
+			mark := " "
+			if profile == current {
+				mark = "*"
+			}
+			fmt.Println(mark, profile)
+		}
+		return
+	}
+	if err := CheckProfile(args[0]); err != nil {
+		fmt.Println("❗ 错误:", err)
+		return
+	}
+	previous := Profile
+	Profile = args[0]
+	userInfo, err := LoadUserInfo()
+	if errors.Is(err, ErrNotLoggedIn) {
+		userInfo, err = LoginByQR(os.Stdout)
+	}
+	if err != nil {
+		Profile = previous
+		fmt.Println("❗ 错误:", err)
+		return
+	}
+	r.UserInfo = userInfo
+	PrintAccount(userInfo)
+}
+
+func (r *REPL) cmdQuit(args []string) {
+	r.quit = true
+}
@@ -0,0 +1,399 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/iuroc/gododo/biliqr"
+	"github.com/skip2/go-qrcode"
+)
+
+// JSON-RPC 错误码。
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	// 未登录或登录已失效，需要先调用 login.start。
+	RPCNotLoggedIn = -32001
+	// 上传失败。
+	RPCUploadFailed = -32002
+	// 请求已被 cancel 取消。
+	RPCRequestCancelled = -32800
+)
+
+// RPCError JSON-RPC 错误对象。
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return e.Message
+}
+
+type rpcMessage struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  any              `json:"result,omitempty"`
+	Error   *RPCError        `json:"error,omitempty"`
+}
+
+// RPCServer 通过标准输入输出提供 JSON-RPC 2.0 接口，每行一条消息，供编辑器插件等程序调用。
+//
+//	status                       登录状态，返回 {loggedIn, uid}
+//	login.start                  创建登录二维码，返回 {url, qrcode}，qrcode 为 PNG 的 data URL
+//	login.cancel                 停止等待扫码
+//	upload {path}                上传本地文件，返回上传结果
+//	upload {name, data}          上传 Base64 编码的文件内容
+//	cancel {id}                  取消正在进行的请求，也可以使用 $/cancelRequest
+//
+// 扫码完成后发送 login.completed 或 login.failed 通知，上传过程中发送 upload.progress 通知。
+// 登录状态保存在内存中，在多次调用之间共享。请求并发处理，响应的顺序与请求不一定相同。
+type RPCServer struct {
+	UserInfo *UserInfo
+	mu       sync.Mutex
+	pending  map[string]context.CancelFunc
+	login    context.CancelFunc
+	writeMu  sync.Mutex
+	encoder  *json.Encoder
+	wg       sync.WaitGroup
+}
+
+// Serve 读取 r 中的请求，将响应和通知写入 w，读取到 EOF 后等待全部请求结束再返回。
+func (s *RPCServer) Serve(r io.Reader, w io.Writer) error {
+	s.encoder = json.NewEncoder(w)
+	s.pending = map[string]context.CancelFunc{}
+	defer s.stop()
+	scanner := bufio.NewScanner(r)
+	// Base64 上传的请求可能很长。
+	scanner.Buffer(make([]byte, 64*1024), 1<<30)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var message rpcMessage
+		if err := json.Unmarshal(line, &message); err != nil {
+			s.send(rpcMessage{ID: rawNull(), Error: &RPCError{RPCParseError, "JSON 格式错误: " + err.Error()}})
+			continue
+		}
+		if message.JSONRPC != "2.0" || message.Method == "" {
+			s.send(rpcMessage{ID: idOrNull(message.ID), Error: &RPCError{RPCInvalidRequest, "无效的请求"}})
+			continue
+		}
+		s.dispatch(message)
+	}
+	return scanner.Err()
+}
+
+// stop 取消全部请求和扫码登录，等待请求结束。
+func (s *RPCServer) stop() {
+	s.mu.Lock()
+	for _, cancel := range s.pending {
+		cancel()
+	}
+	if s.login != nil {
+		s.login()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *RPCServer) dispatch(message rpcMessage) {
+	var handler func(ctx context.Context, id *json.RawMessage, params json.RawMessage) (any, error)
+	switch message.Method {
+	case "status":
+		handler = s.status
+	case "login.start":
+		handler = s.loginStart
+	case "login.cancel":
+		handler = s.loginCancel
+	case "upload":
+		handler = s.upload
+	case "cancel", "$/cancelRequest":
+		handler = s.cancel
+	default:
+		if message.ID != nil {
+			s.send(rpcMessage{ID: message.ID, Error: &RPCError{RPCMethodNotFound, "未知方法: " + message.Method}})
+		}
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	key := ""
+	if message.ID != nil {
+		key = string(*message.ID)
+		s.mu.Lock()
+		s.pending[key] = cancel
+		s.mu.Unlock()
+	}
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer cancel()
+		result, err := handler(ctx, message.ID, message.Params)
+		if message.ID == nil {
+			return
+		}
+		s.mu.Lock()
+		delete(s.pending, key)
+		s.mu.Unlock()
+		if err != nil {
+			s.send(rpcMessage{ID: message.ID, Error: toRPCError(ctx, err)})
+			return
+		}
+		if result == nil {
+			result = struct{}{}
+		}
+		s.send(rpcMessage{ID: message.ID, Result: result})
+	}()
+}
+
+// toRPCError 将处理请求时的错误转换为 JSON-RPC 错误对象。
+func toRPCError(ctx context.Context, err error) *RPCError {
+	var rpcErr *RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr
+	}
+	if ctx.Err() != nil {
+		return &RPCError{RPCRequestCancelled, "请求已取消"}
+	}
+	if errors.Is(err, ErrNotLoggedIn) {
+		return &RPCError{RPCNotLoggedIn, err.Error()}
+	}
+	return &RPCError{RPCInternalError, err.Error()}
+}
+
+// send 写入一条消息，可以在多个 goroutine 中同时调用。
+func (s *RPCServer) send(message rpcMessage) {
+	message.JSONRPC = "2.0"
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	if err := s.encoder.Encode(message); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+	}
+}
+
+// notify 发送通知。
+func (s *RPCServer) notify(method string, params any) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	s.send(rpcMessage{Method: method, Params: data})
+}
+
+func rawNull() *json.RawMessage {
+	null := json.RawMessage("null")
+	return &null
+}
+
+func idOrNull(id *json.RawMessage) *json.RawMessage {
+	if id == nil {
+		return rawNull()
+	}
+	return id
+}
+
+// parseParams 解析请求参数，参数为空时保持 v 不变。
+func parseParams(params json.RawMessage, v any) error {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &RPCError{RPCInvalidParams, "参数错误: " + err.Error()}
+	}
+	return nil
+}
+
+func (s *RPCServer) userInfo() *UserInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.UserInfo
+}
+
+func (s *RPCServer) status(ctx context.Context, id *json.RawMessage, params json.RawMessage) (any, error) {
+	userInfo := s.userInfo()
+	status := struct {
+		LoggedIn bool   `json:"loggedIn"`
+		UID      string `json:"uid,omitempty"`
+		Login    bool   `json:"loginPending"`
+	}{LoggedIn: userInfo != nil}
+	if userInfo != nil {
+		status.UID = userInfo.UID
+	}
+	s.mu.Lock()
+	status.Login = s.login != nil
+	s.mu.Unlock()
+	return status, nil
+}
+
+func (s *RPCServer) loginStart(ctx context.Context, id *json.RawMessage, params json.RawMessage) (any, error) {
+	qr, info, err := biliqr.NewLoginQR(qrcode.Medium)
+	if err != nil {
+		return nil, err
+	}
+	png, err := qr.PNG(256)
+	if err != nil {
+		return nil, err
+	}
+	loginCtx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if s.login != nil {
+		// 重新创建二维码时，停止等待旧的二维码。
+		s.login()
+	}
+	s.login = cancel
+	s.mu.Unlock()
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		userInfo, err := pollQRLogin(loginCtx, info.OauthKey)
+		s.mu.Lock()
+		current := loginCtx.Err() == nil
+		if current {
+			s.login = nil
+			if err == nil {
+				s.UserInfo = userInfo
+			}
+		}
+		s.mu.Unlock()
+		cancel()
+		if !current {
+			return
+		}
+		if err != nil {
+			s.notify("login.failed", map[string]string{"message": err.Error()})
+			return
+		}
+		s.notify("login.completed", map[string]string{"uid": userInfo.UID})
+	}()
+	return map[string]string{
+		"url":    info.URL,
+		"qrcode": "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+func (s *RPCServer) loginCancel(ctx context.Context, id *json.RawMessage, params json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cancelled := s.login != nil
+	if cancelled {
+		s.login()
+		s.login = nil
+	}
+	return map[string]bool{"cancelled": cancelled}, nil
+}
+
+func (s *RPCServer) upload(ctx context.Context, id *json.RawMessage, params json.RawMessage) (any, error) {
+	var p struct {
+		Path string `json:"path"`
+		Name string `json:"name"`
+		Data string `json:"data"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if (p.Path == "") == (p.Name == "") {
+		return nil, &RPCError{RPCInvalidParams, "参数错误: 需要 path，或 name 和 data"}
+	}
+	var data []byte
+	if p.Path == "" {
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(p.Data); err != nil {
+			return nil, &RPCError{RPCInvalidParams, "参数错误: data 不是有效的 Base64"}
+		}
+	}
+	userInfo := s.userInfo()
+	if userInfo == nil {
+		return nil, &RPCError{RPCNotLoggedIn, ErrNotLoggedIn.Error()}
+	}
+	progress := s.progress(id)
+	var result *UploadResult
+	var err error
+	if p.Path != "" {
+		result, err = UploadFileContext(ctx, p.Path, userInfo, progress)
+	} else {
+		result, err = UploadReader(ctx, p.Name, bytes.NewReader(data), userInfo, progress)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if os.IsNotExist(err) {
+			return nil, &RPCError{RPCInvalidParams, err.Error()}
+		}
+		return nil, &RPCError{RPCUploadFailed, err.Error()}
+	}
+	return result, nil
+}
+
+// progress 返回发送 upload.progress 通知的回调，每增加 1% 发送一次。
+func (s *RPCServer) progress(id *json.RawMessage) func(sent int64, total int64) {
+	if id == nil {
+		return nil
+	}
+	last := int64(-1)
+	return func(sent int64, total int64) {
+		percent := int64(100)
+		if total > 0 {
+			percent = sent * 100 / total
+		}
+		if percent == last {
+			return
+		}
+		last = percent
+		s.notify("upload.progress", map[string]any{"id": id, "sent": sent, "total": total})
+	}
+}
+
+func (s *RPCServer) cancel(ctx context.Context, id *json.RawMessage, params json.RawMessage) (any, error) {
+	var p struct {
+		ID json.RawMessage `json:"id"`
+	}
+	if err := parseParams(params, &p); err != nil {
+		return nil, err
+	}
+	if len(p.ID) == 0 {
+		return nil, &RPCError{RPCInvalidParams, "参数错误: 缺少 id"}
+	}
+	s.mu.Lock()
+	cancel, ok := s.pending[string(p.ID)]
+	s.mu.Unlock()
+	if ok {
+		cancel()
+	}
+	return map[string]bool{"cancelled": ok}, nil
+}
+
+func cmdRPC(args []string) int {
+	flags := newFlagSet("rpc")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	server := &RPCServer{}
+	// 未登录时不退出，由调用方通过 login.start 登录。
+	if userInfo, err := LoadUserInfo(); err == nil {
+		server.UserInfo = userInfo
+	}
+	if err := server.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	return ExitOK
+}
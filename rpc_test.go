@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRPCServer(t *testing.T) {
+	input := strings.Join([]string{
+		`{"jsonrpc":"2.0","id":1,"method":"status"}`,
+		`{"jsonrpc":"2.0","id":2,"method":"nope"}`,
+		`{not json`,
+		`{"jsonrpc":"1.0","id":3,"method":"status"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"upload","params":{"name":"a.txt","data":"!!"}}`,
+		`{"jsonrpc":"2.0","id":5,"method":"upload","params":{}}`,
+		`{"jsonrpc":"2.0","id":6,"method":"upload","params":{"name":"a.txt","data":"aGVsbG8="}}`,
+		`{"jsonrpc":"2.0","id":"c","method":"cancel","params":{"id":99}}`,
+		`{"jsonrpc":"2.0","method":"status"}`,
+	}, "\n")
+	var output strings.Builder
+	if err := (&RPCServer{}).Serve(strings.NewReader(input), &output); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"1":    `"result":{"loggedIn":false,"loginPending":false}`,
+		"2":    `"code":-32601`,
+		"null": `"code":-32700`,
+		"3":    `"code":-32600`,
+		"4":    `"code":-32602`,
+		"5":    `"code":-32602`,
+		"6":    `"code":-32001`,
+		`"c"`:  `"result":{"cancelled":false}`,
+	}
+	scanner := bufio.NewScanner(strings.NewReader(output.String()))
+	for scanner.Scan() {
+		var message struct {
+			ID json.RawMessage `json:"id"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &message); err != nil {
+			t.Fatal(err)
+		}
+		id := string(message.ID)
+		if _, ok := want[id]; !ok {
+			t.Errorf("unexpected message: %s", scanner.Text())
+			continue
+		}
+		if !strings.Contains(scanner.Text(), want[id]) || !strings.Contains(scanner.Text(), `"jsonrpc":"2.0"`) {
+			t.Errorf("id %s: %s, want %s", id, scanner.Text(), want[id])
+		}
+		delete(want, id)
+	}
+	if len(want) != 0 {
+		t.Errorf("missing responses: %v", want)
+	}
+}
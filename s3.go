@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// S3IndexFile 存储桶和对象键到直链的索引文件名，位于 [DataPath] 指定的目录。
+const S3IndexFile = "s3-index.json"
+
+// S3Server 最小的 S3 兼容接口，支持路径风格的 PutObject、HeadObject 和 GetObject。
+//
+// 上传的对象通过 [dodo.UploadWork] 发布到 DoDo，GetObject 重定向到直链。对象的 ETag 为文件的 MD5。
+type S3Server struct {
+	UserInfo *UserInfo
+	Auth     *SigV4
+	Index    *ObjectIndex
+}
+
+type s3Error struct {
+	XMLName  xml.Name `xml:"Error"`
+	Code     string   `xml:"Code"`
+	Message  string   `xml:"Message"`
+	Resource string   `xml:"Resource"`
+}
+
+func writeS3Error(w http.ResponseWriter, r *http.Request, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(s3Error{Code: code, Message: message, Resource: r.URL.Path})
+}
+
+// ServeHTTP 处理 /bucket/key 形式的请求。
+func (s *S3Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth, err := s.Auth.Verify(r)
+	if err != nil {
+		writeS3Error(w, r, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+		return
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", "只支持对象的 PUT、HEAD 和 GET 请求")
+		return
+	}
+	query := r.URL.Query()
+	for _, name := range []string{"uploads", "uploadId", "acl", "tagging", "partNumber"} {
+		if query.Has(name) {
+			writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", "不支持 "+name)
+			return
+		}
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("X-Amz-Copy-Source") != "" {
+			writeS3Error(w, r, http.StatusNotImplemented, "NotImplemented", "不支持 CopyObject")
+			return
+		}
+		s.putObject(w, r, auth, bucket, key)
+	case http.MethodHead, http.MethodGet:
+		object := s.Index.Get(bucket + "/" + key)
+		if object == nil {
+			writeS3Error(w, r, http.StatusNotFound, "NoSuchKey", "对象不存在")
+			return
+		}
+		setS3ObjectHeader(w, object)
+		if r.Method == http.MethodGet {
+			http.Redirect(w, r, object.URL, http.StatusTemporaryRedirect)
+			return
+		}
+		w.Header().Set("Content-Length", strconv.FormatInt(object.Size, 10))
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeS3Error(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed", "不支持 "+r.Method)
+	}
+}
+
+func (s *S3Server) putObject(w http.ResponseWriter, r *http.Request, auth *sigV4Auth, bucket string, key string) {
+	body, err := auth.Body(r)
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadRequest, "InvalidRequest", err.Error())
+		return
+	}
+	result, err := UploadReader(r.Context(), path.Base(key), body, s.UserInfo, nil)
+	if err != nil {
+		writeS3Error(w, r, http.StatusBadGateway, "InternalError", err.Error())
+		return
+	}
+	object := &IndexObject{
+		URL:         result.URL,
+		MD5:         result.MD5,
+		Size:        result.Size,
+		ContentType: r.Header.Get("Content-Type"),
+		Time:        result.Time,
+	}
+	if err = s.Index.Put(bucket+"/"+key, object); err != nil {
+		writeS3Error(w, r, http.StatusInternalServerError, "InternalError", err.Error())
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%s/%s -> %s\n", bucket, key, result.URL)
+	w.Header().Set("ETag", `"`+object.MD5+`"`)
+	w.Header().Set("X-Dodo-Url", object.URL)
+	w.WriteHeader(http.StatusOK)
+}
+
+func setS3ObjectHeader(w http.ResponseWriter, object *IndexObject) {
+	contentType := object.ContentType
+	if contentType == "" {
+		contentType = MediaType(path.Ext(object.URL))
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("ETag", `"`+object.MD5+`"`)
+	w.Header().Set("Last-Modified", object.Time.UTC().Format(http.TimeFormat))
+	w.Header().Set("X-Dodo-Url", object.URL)
+}
+
+// ParseS3Keys 解析 ACCESS_KEY:SECRET_KEY 格式的密钥。
+func ParseS3Keys(lines []string) (map[string]string, error) {
+	keys := map[string]string{}
+	for _, line := range lines {
+		accessKey, secretKey, ok := strings.Cut(line, ":")
+		if !ok || accessKey == "" || secretKey == "" {
+			return nil, errors.New("密钥格式应为 ACCESS_KEY:SECRET_KEY")
+		}
+		keys[accessKey] = secretKey
+	}
+	return keys, nil
+}
+
+func cmdS3(args []string) int {
+	flags := newFlagSet("s3")
+	addr := flags.String("addr", "127.0.0.1:9000", "监听地址")
+	var keyFlags stringList
+	flags.Var(&keyFlags, "key", "ACCESS_KEY:SECRET_KEY，可以重复指定")
+	keysFile := flags.String("keys-file", "", "密钥文件，每行一个 ACCESS_KEY:SECRET_KEY")
+	indexPath := flags.String("index", DataPath(S3IndexFile), "对象索引文件")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	lines, err := readKeys(keyFlags, *keysFile, "GODODO_S3_KEYS")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	keys, err := ParseS3Keys(lines)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitUsage
+	}
+	if len(keys) == 0 {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 请通过 --key、--keys-file 或环境变量 GODODO_S3_KEYS 设置密钥")
+		return ExitUsage
+	}
+	index, err := LoadObjectIndex(*indexPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	server := &S3Server{UserInfo: userInfo, Auth: &SigV4{Keys: keys}, Index: index}
+	accessKeys := []string{}
+	for accessKey := range keys {
+		accessKeys = append(accessKeys, accessKey)
+	}
+	sort.Strings(accessKeys)
+	fmt.Fprintf(os.Stderr, "👂 正在监听 http://%s，Access Key: %s\n", *addr, strings.Join(accessKeys, ", "))
+	if err = newHTTPServer(*addr, server).ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	return ExitOK
+}
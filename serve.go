@@ -0,0 +1,323 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iuroc/gododo/dodo"
+)
+
+var md5Pattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// Server 上传网关，使用同一个已登录的账号为局域网中的其他工具提供上传接口。
+//
+//	GET  /api/health          健康检查，不需要 API Key
+//	POST /api/upload          multipart/form-data 上传一个或多个文件，返回上传结果数组
+//	PUT  /api/upload/raw      请求体为文件内容，文件名由 X-File-Name 头或 name 参数指定，返回上传结果
+//	GET  /api/files/{md5}     按 MD5 查询直链，先查找上传历史，再查询 DoDo 的上传记录
+//	GET  /api/history         本次运行中上传的文件，q 参数用于搜索
+//	GET  /api/events          以 SSE 推送上传到 DoDo 的进度
+//	GET  /                    网页界面
+//
+// 上传接口的 id 参数不为空时，会通过 /api/events 推送该文件的进度和结果。
+//
+// 除健康检查和网页界面外，请求需要在 Authorization: Bearer 或 X-API-Key 头中携带 API Key，
+// 无法设置请求头时也可以使用 key 参数。为了防止其他网页跨站上传，带有 Origin 头的请求必须来自网页界面；
+// 没有设置 API Key 时，Host 还必须是本机地址，防止 DNS 重绑定。
+type Server struct {
+	UserInfo *UserInfo
+	// 允许的 API Key，为空时不校验。
+	APIKeys []string
+	events  eventHub
+	mu      sync.Mutex
+	results []*UploadResult
+}
+
+// Handler 返回网关的 HTTP 处理器。
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/health", s.handleHealth)
+	mux.Handle("POST /api/upload", s.auth(s.handleUpload))
+	mux.Handle("PUT /api/upload/raw", s.auth(s.handleRawUpload))
+	mux.Handle("POST /api/upload/raw", s.auth(s.handleRawUpload))
+	mux.Handle("GET /api/files/{md5}", s.auth(s.handleLookup))
+	mux.Handle("GET /api/history", s.auth(s.handleHistory))
+	mux.Handle("GET /api/events", s.auth(s.handleEvents))
+	mux.Handle("GET /", http.FileServerFS(webFS))
+	return mux
+}
+
+// auth 校验请求的来源和 API Key。
+func (s *Server) auth(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.checkOrigin(r); err != nil {
+			writeError(w, http.StatusForbidden, err)
+			return
+		}
+		if !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("API Key 无效"))
+			return
+		}
+		handler(w, r)
+	})
+}
+
+// checkOrigin 拒绝其他网页发起的请求，浏览器不会为简单的 POST 请求发送预检请求。
+func (s *Server) checkOrigin(r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		u, err := url.Parse(origin)
+		if err != nil || u.Host != r.Host {
+			return errors.New("不允许跨站请求: " + origin)
+		}
+	}
+	if len(s.APIKeys) == 0 && !isLoopbackHost(r.Host) {
+		return errors.New("没有设置 API Key 时只允许通过本机地址访问")
+	}
+	return nil
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if len(s.APIKeys) == 0 {
+		return true
+	}
+	key := r.URL.Query().Get("key")
+	if header := r.Header.Get("X-API-Key"); header != "" {
+		key = header
+	}
+	if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		key = strings.TrimSpace(bearer)
+	}
+	if key == "" {
+		return false
+	}
+	for _, apiKey := range s.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// newHTTPServer 创建监听 addr 的 HTTP 服务，限制读取请求头和空闲连接的时间。
+//
+// 上传的文件可能很大，因此不限制读取请求体和写入响应的时间。
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	reader, err := r.MultipartReader()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	results := []*UploadResult{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if part.FileName() == "" {
+			continue
+		}
+		result, err := UploadReader(r.Context(), part.FileName(), part, s.UserInfo, nil)
+		if err != nil {
+			writeError(w, http.StatusBadGateway, fmt.Errorf("%s: %w", part.FileName(), err))
+			return
+		}
+		s.addResult("", result)
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("请求中没有文件"))
+		return
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+func (s *Server) handleRawUpload(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if header := r.Header.Get("X-File-Name"); header != "" {
+		// 非 ASCII 文件名需要经过 URL 编码。
+		if unescaped, err := url.PathUnescape(header); err == nil {
+			name = unescaped
+		} else {
+			name = header
+		}
+	}
+	if name == "" {
+		writeError(w, http.StatusBadRequest, errors.New("缺少文件名，请设置 X-File-Name 头或 name 参数"))
+		return
+	}
+	id := r.URL.Query().Get("id")
+	if !uploadIDPattern.MatchString(id) {
+		id = ""
+	}
+	result, err := UploadReader(r.Context(), name, r.Body, s.UserInfo, s.events.progress(id, name))
+	if err != nil {
+		if id != "" {
+			s.events.publish(ServerEvent{ID: id, Name: name, Stage: "error", Error: err.Error()})
+		}
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	s.addResult(id, result)
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) handleLookup(w http.ResponseWriter, r *http.Request) {
+	md5 := strings.ToLower(r.PathValue("md5"))
+	if !md5Pattern.MatchString(md5) {
+		writeError(w, http.StatusBadRequest, errors.New("MD5 格式错误"))
+		return
+	}
+	history, _ := ReadHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].MD5 == md5 {
+			writeJSON(w, http.StatusOK, history[i])
+			return
+		}
+	}
+	work := &dodo.UploadWork{MD5: md5, Token: s.UserInfo.Token, UID: s.UserInfo.UID}
+	record, err := work.History()
+	if err != nil {
+		writeError(w, http.StatusBadGateway, err)
+		return
+	}
+	if !record.HasRecord {
+		writeError(w, http.StatusNotFound, errors.New("没有上传记录"))
+		return
+	}
+	writeJSON(w, http.StatusOK, &UploadResult{MD5: md5, URL: record.ResourceURL, Cached: true})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// stringList 可以重复指定的命令行参数。
+type stringList []string
+
+func (list *stringList) String() string {
+	return strings.Join(*list, ",")
+}
+
+func (list *stringList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// LoadAPIKeys 合并命令行参数、Key 文件和环境变量 GODODO_API_KEYS 中的 API Key。
+//
+// Key 文件每行一个 Key，忽略空行和 # 开头的行，环境变量使用逗号分隔。
+func LoadAPIKeys(keys []string, path string) ([]string, error) {
+	return readKeys(keys, path, "GODODO_API_KEYS")
+}
+
+// readKeys 合并命令行参数、Key 文件和环境变量 env 中的 Key。
+func readKeys(keys []string, path string, env string) ([]string, error) {
+	lines := append([]string{}, keys...)
+	lines = append(lines, strings.Split(os.Getenv(env), ",")...)
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, strings.Split(string(data), "\n")...)
+	}
+	result := []string{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			result = append(result, line)
+		}
+	}
+	return result, nil
+}
+
+// isLoopback 判断监听地址是否只允许本机访问。
+func isLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	return isLoopbackHost(host)
+}
+
+// isLoopbackHost 判断 Host 头中的主机名是否为本机地址，端口可以省略。
+func isLoopbackHost(host string) bool {
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func cmdServe(args []string) int {
+	flags := newFlagSet("serve")
+	addr := flags.String("addr", "127.0.0.1:8686", "监听地址")
+	var keys stringList
+	flags.Var(&keys, "key", "允许的 API Key，可以重复指定")
+	keysFile := flags.String("keys-file", "", "API Key 文件，每行一个")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	apiKeys, err := LoadAPIKeys(keys, *keysFile)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	if len(apiKeys) == 0 && !isLoopback(*addr) {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 监听非本机地址时必须设置 API Key")
+		return ExitUsage
+	}
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	server := &Server{UserInfo: userInfo, APIKeys: apiKeys}
+	fmt.Fprintf(os.Stderr, "👂 正在监听 http://%s\n", *addr)
+	if err = newHTTPServer(*addr, server.Handler()).ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	return ExitOK
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServer(t *testing.T) {
+	t.Setenv("GODODO_HOME", t.TempDir())
+	AppendHistory(&UploadResult{Base: "a.png", MD5: "0123456789abcdef0123456789abcdef", URL: "https://files.imdodo.com/dodo/0123456789abcdef0123456789abcdef.png"})
+	server := httptest.NewServer((&Server{UserInfo: &UserInfo{UID: "1"}, APIKeys: []string{"secret"}}).Handler())
+	defer server.Close()
+	tests := []struct {
+		method, path, key string
+		status            int
+		want              string
+	}{
+		{"GET", "/api/health", "", http.StatusOK, `{"status":"ok"}`},
+		{"PUT", "/api/upload/raw?name=a.txt", "", http.StatusUnauthorized, `"error"`},
+		{"PUT", "/api/upload/raw?name=a.txt", "wrong", http.StatusUnauthorized, `"error"`},
+		{"PUT", "/api/upload/raw", "secret", http.StatusBadRequest, `X-File-Name`},
+		{"GET", "/api/files/nope", "secret", http.StatusBadRequest, `"error"`},
+		{"GET", "/api/files/0123456789ABCDEF0123456789ABCDEF", "secret", http.StatusOK, `"url":"https://files.imdodo.com/dodo/0123456789abcdef0123456789abcdef.png"`},
+	}
+	for _, test := range tests {
+		request, _ := http.NewRequest(test.method, server.URL+test.path, strings.NewReader("hello"))
+		if test.key != "" {
+			request.Header.Set("Authorization", "Bearer "+test.key)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body json.RawMessage
+		json.NewDecoder(response.Body).Decode(&body)
+		response.Body.Close()
+		if response.StatusCode != test.status || !strings.Contains(string(body), test.want) {
+			t.Errorf("%s %s = %d %s", test.method, test.path, response.StatusCode, body)
+		}
+	}
+}
+
+func TestSafeFileName(t *testing.T) {
+	for name, want := range map[string]string{"a.png": "a.png", "../../etc/passwd": "passwd", `C:\Users\a\b.txt`: "b.txt", "": "file", "..": "file"} {
+		if got := SafeFileName(name); got != want {
+			t.Errorf("SafeFileName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestServerEvents(t *testing.T) {
+	s := &Server{UserInfo: &UserInfo{UID: "1"}, APIKeys: []string{"secret"}}
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+	response, err := http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if !strings.Contains(string(page), "EventSource") {
+		t.Fatal("index.html not served")
+	}
+	response, err = http.Get(server.URL + "/api/events?key=secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+	if response.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatal(response.Status)
+	}
+	// 等待连接订阅事件后再上报进度。
+	for {
+		s.events.mu.Lock()
+		subscribed := len(s.events.clients) > 0
+		s.events.mu.Unlock()
+		if subscribed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	progress := s.events.progress("abc", "a.png")
+	progress(1, 1000)
+	progress(5, 1000)
+	progress(1000, 1000)
+	reader := bufio.NewReader(response.Body)
+	for _, want := range []string{`"sent":1,`, `"sent":1000,`} {
+		line, err := reader.ReadString('\n')
+		reader.ReadString('\n')
+		if err != nil || !strings.Contains(line, `"id":"abc"`) || !strings.Contains(line, want) {
+			t.Fatalf("%q %v, want %s", line, err, want)
+		}
+	}
+}
+
+func TestServerOrigin(t *testing.T) {
+	t.Setenv("GODODO_HOME", t.TempDir())
+	server := httptest.NewServer((&Server{UserInfo: &UserInfo{UID: "1"}}).Handler())
+	defer server.Close()
+	tests := []struct {
+		host, origin string
+		status       int
+	}{
+		{"", "", http.StatusOK},
+		{"", server.URL, http.StatusOK},
+		{"", "http://evil.example", http.StatusForbidden},
+		{"", "null", http.StatusForbidden},
+		{"localhost:8686", "", http.StatusOK},
+		{"evil.example:8686", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		request, _ := http.NewRequest("GET", server.URL+"/api/history", nil)
+		if test.host != "" {
+			request.Host = test.host
+		}
+		if test.origin != "" {
+			request.Header.Set("Origin", test.origin)
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response.Body.Close()
+		if response.StatusCode != test.status {
+			t.Errorf("Host %q Origin %q = %d, want %d", test.host, test.origin, response.StatusCode, test.status)
+		}
+	}
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AWS Signature Version 4 中使用的常量。
+const (
+	sigV4Algorithm    = "AWS4-HMAC-SHA256"
+	sigV4TimeFormat   = "20060102T150405Z"
+	emptySHA256       = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	unsignedPayload   = "UNSIGNED-PAYLOAD"
+	streamingPayload  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD"
+	streamingTrailer  = "STREAMING-AWS4-HMAC-SHA256-PAYLOAD-TRAILER"
+	streamingUnsigned = "STREAMING-UNSIGNED-PAYLOAD-TRAILER"
+	sigV4MaxClockSkew = 15 * time.Minute
+	// 预签名 URL 的最长有效期，与 S3 相同为 7 天。
+	sigV4MaxExpires    = 7 * 24 * 60 * 60
+	sigV4MaxChunkSize  = 16 << 20
+	sigV4ChunkStringID = "AWS4-HMAC-SHA256-PAYLOAD"
+)
+
+// SigV4 校验 AWS Signature Version 4 签名，支持 Authorization 头和预签名 URL。
+type SigV4 struct {
+	// Access Key 到 Secret Key 的映射。
+	Keys map[string]string
+	// 返回当前时间，为空时使用 time.Now，用于测试。
+	Now func() time.Time
+}
+
+// sigV4Auth 请求中的签名信息。
+type sigV4Auth struct {
+	AccessKey     string
+	Date          time.Time
+	Scope         string
+	SignedHeaders []string
+	Signature     string
+	// 请求体的 SHA-256，或 UNSIGNED-PAYLOAD、STREAMING-* 等特殊值。
+	PayloadHash string
+	signingKey  []byte
+}
+
+// Verify 校验请求的签名，返回签名信息。
+func (v *SigV4) Verify(r *http.Request) (*sigV4Auth, error) {
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+	query := r.URL.Query()
+	var auth *sigV4Auth
+	var err error
+	if header := r.Header.Get("Authorization"); header != "" {
+		auth, err = parseSigV4Header(header)
+		if err != nil {
+			return nil, err
+		}
+		auth.PayloadHash = r.Header.Get("X-Amz-Content-Sha256")
+		if auth.PayloadHash == "" {
+			return nil, errors.New("缺少 x-amz-content-sha256 头")
+		}
+		// 没有 x-amz-date 头时使用 HTTP 格式的 Date 头。
+		if date := r.Header.Get("X-Amz-Date"); date != "" {
+			if auth.Date, err = time.Parse(sigV4TimeFormat, date); err != nil {
+				return nil, errors.New("x-amz-date 格式错误")
+			}
+		} else if auth.Date, err = http.ParseTime(r.Header.Get("Date")); err != nil {
+			return nil, errors.New("缺少 x-amz-date 头或 Date 头格式错误")
+		}
+		auth.Date = auth.Date.UTC()
+		if skew := now().Sub(auth.Date); skew > sigV4MaxClockSkew || skew < -sigV4MaxClockSkew {
+			return nil, errors.New("请求时间与服务器时间相差过大")
+		}
+	} else if query.Get("X-Amz-Algorithm") == sigV4Algorithm {
+		auth, err = parsePresignedQuery(query)
+		if err != nil {
+			return nil, err
+		}
+		expires, err := strconv.Atoi(query.Get("X-Amz-Expires"))
+		if err != nil || expires < 1 || expires > sigV4MaxExpires {
+			return nil, fmt.Errorf("X-Amz-Expires 应为 1 到 %d 秒", sigV4MaxExpires)
+		}
+		if now().After(auth.Date.Add(time.Duration(expires) * time.Second)) {
+			return nil, errors.New("预签名 URL 已过期")
+		}
+		auth.PayloadHash = unsignedPayload
+		query.Del("X-Amz-Signature")
+	} else {
+		return nil, errors.New("缺少签名")
+	}
+	if !slices.Contains(auth.SignedHeaders, "host") {
+		return nil, errors.New("签名必须包含 host 头")
+	}
+	secret, ok := v.Keys[auth.AccessKey]
+	if !ok {
+		return nil, errors.New("Access Key 不存在")
+	}
+	scope := strings.Split(auth.Scope, "/")
+	if len(scope) != 4 || scope[0] != auth.Date.Format("20060102") || scope[3] != "aws4_request" {
+		return nil, errors.New("签名范围错误")
+	}
+	auth.signingKey = sigV4SigningKey(secret, scope[0], scope[1], scope[2])
+	canonical := canonicalRequest(r, query, auth.SignedHeaders, auth.PayloadHash)
+	stringToSign := strings.Join([]string{sigV4Algorithm, auth.Date.Format(sigV4TimeFormat), auth.Scope, sha256Hex([]byte(canonical))}, "\n")
+	expected := hex.EncodeToString(hmacSHA256(auth.signingKey, stringToSign))
+	if !hmac.Equal([]byte(expected), []byte(auth.Signature)) {
+		return nil, errors.New("签名不匹配")
+	}
+	return auth, nil
+}
+
+// parseSigV4Header 解析 Authorization 头：
+//
+//	AWS4-HMAC-SHA256 Credential=AK/20130524/us-east-1/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature=...
+func parseSigV4Header(header string) (*sigV4Auth, error) {
+	fields, ok := strings.CutPrefix(header, sigV4Algorithm+" ")
+	if !ok {
+		return nil, errors.New("只支持 AWS4-HMAC-SHA256 签名")
+	}
+	values := map[string]string{}
+	for _, field := range strings.Split(fields, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(field), "=")
+		values[key] = value
+	}
+	return newSigV4Auth(values["Credential"], values["SignedHeaders"], values["Signature"])
+}
+
+func parsePresignedQuery(query url.Values) (*sigV4Auth, error) {
+	auth, err := newSigV4Auth(query.Get("X-Amz-Credential"), query.Get("X-Amz-SignedHeaders"), query.Get("X-Amz-Signature"))
+	if err != nil {
+		return nil, err
+	}
+	if auth.Date, err = time.Parse(sigV4TimeFormat, query.Get("X-Amz-Date")); err != nil {
+		return nil, errors.New("X-Amz-Date 格式错误")
+	}
+	return auth, nil
+}
+
+func newSigV4Auth(credential string, signedHeaders string, signature string) (*sigV4Auth, error) {
+	accessKey, scope, ok := strings.Cut(credential, "/")
+	if !ok || signedHeaders == "" || signature == "" {
+		return nil, errors.New("签名格式错误")
+	}
+	return &sigV4Auth{
+		AccessKey:     accessKey,
+		Scope:         scope,
+		SignedHeaders: strings.Split(signedHeaders, ";"),
+		Signature:     signature,
+	}, nil
+}
+
+// canonicalRequest 按照 SigV4 的规则拼接规范请求。
+func canonicalRequest(r *http.Request, query url.Values, signedHeaders []string, payloadHash string) string {
+	var b strings.Builder
+	b.WriteString(r.Method + "\n")
+	b.WriteString(sigV4Escape(r.URL.Path, false) + "\n")
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key, true)+"="+sigV4Escape(value, true))
+		}
+	}
+	b.WriteString(strings.Join(pairs, "&") + "\n")
+	for _, name := range signedHeaders {
+		var value string
+		switch name {
+		case "host":
+			value = r.Host
+		case "content-length":
+			value = strconv.FormatInt(r.ContentLength, 10)
+		default:
+			value = strings.Join(r.Header.Values(name), ",")
+		}
+		b.WriteString(name + ":" + strings.Join(strings.Fields(value), " ") + "\n")
+	}
+	b.WriteString("\n" + strings.Join(signedHeaders, ";") + "\n")
+	b.WriteString(payloadHash)
+	return b.String()
+}
+
+// sigV4Escape 对 A-Z、a-z、0-9、-、.、_、~ 以外的字符进行百分号编码，slash 为 false 时保留 /。
+func sigV4Escape(text string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' || c == '/' && !slash {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func sigV4SigningKey(secret string, date string, region string, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Body 返回解码并校验后的请求体。
+//
+// 普通请求体在读取结束时校验 SHA-256，aws-chunked 编码的请求体会逐块校验签名，校验失败时 Read 返回错误。
+func (auth *sigV4Auth) Body(r *http.Request) (io.Reader, error) {
+	switch auth.PayloadHash {
+	case unsignedPayload:
+		return r.Body, nil
+	case streamingPayload, streamingTrailer:
+		return &awsChunkedReader{reader: bufio.NewReader(r.Body), auth: auth, previous: auth.Signature}, nil
+	case streamingUnsigned:
+		return &awsChunkedReader{reader: bufio.NewReader(r.Body)}, nil
+	}
+	if len(auth.PayloadHash) != 64 {
+		return nil, errors.New("不支持的 x-amz-content-sha256: " + auth.PayloadHash)
+	}
+	return &sha256Reader{reader: r.Body, hash: sha256.New(), want: auth.PayloadHash}, nil
+}
+
+// sha256Reader 在读取结束时校验内容的 SHA-256。
+type sha256Reader struct {
+	reader io.Reader
+	hash   hash.Hash
+	want   string
+}
+
+func (r *sha256Reader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.hash.Write(p[:n])
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.want {
+		return n, errors.New("请求体的 SHA-256 与 x-amz-content-sha256 不一致")
+	}
+	return n, err
+}
+
+// awsChunkedReader 解码 aws-chunked 编码的请求体，auth 不为空时校验每一块的签名：
+//
+//	<hex size>;chunk-signature=<signature>\r\n<data>\r\n ... 0;chunk-signature=<signature>\r\n[trailers]\r\n
+type awsChunkedReader struct {
+	reader   *bufio.Reader
+	auth     *sigV4Auth
+	previous string
+	chunk    []byte
+	done     bool
+}
+
+func (r *awsChunkedReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
+
+// next 读取并校验下一块。
+func (r *awsChunkedReader) next() error {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return errors.New("aws-chunked 编码错误")
+	}
+	sizeText, extension, _ := strings.Cut(strings.TrimRight(line, "\r\n"), ";")
+	size, err := strconv.ParseInt(sizeText, 16, 64)
+	if err != nil || size < 0 || size > sigV4MaxChunkSize {
+		return errors.New("aws-chunked 块大小错误")
+	}
+	chunk := make([]byte, size)
+	if _, err = io.ReadFull(r.reader, chunk); err != nil {
+		return errors.New("aws-chunked 块不完整")
+	}
+	if r.auth != nil {
+		signature, _ := strings.CutPrefix(extension, "chunk-signature=")
+		stringToSign := strings.Join([]string{
+			sigV4ChunkStringID,
+			r.auth.Date.Format(sigV4TimeFormat),
+			r.auth.Scope,
+			r.previous,
+			emptySHA256,
+			sha256Hex(chunk),
+		}, "\n")
+		expected := hex.EncodeToString(hmacSHA256(r.auth.signingKey, stringToSign))
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			return errors.New("aws-chunked 块签名不匹配")
+		}
+		r.previous = signature
+	}
+	if size == 0 {
+		// 最后一块之后是可选的 trailer，校验和由 DoDo 的 MD5 保证，这里直接丢弃。
+		r.done = true
+		io.Copy(io.Discard, r.reader)
+		return nil
+	}
+	if _, err = r.reader.Discard(2); err != nil {
+		return errors.New("aws-chunked 块不完整")
+	}
+	r.chunk = chunk
+	return nil
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	htmlTag        = regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+	htmlURLAttr    = regexp.MustCompile(`(?i)\s(?:src|href|poster|data-src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	htmlSrcsetAttr = regexp.MustCompile(`(?i)\s(?:srcset|data-srcset)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+	cssURL         = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^\s"')]+))\s*\)`)
+)
+
+// SiteOffloader 将静态网站中较大的媒体文件上传到 DoDo，并将 HTML 和 CSS 中的引用替换为直链。
+type SiteOffloader struct {
+	// 网站根目录的绝对路径，以 / 开头的引用相对于该目录。
+	Root string
+	// 只上传不小于该大小的文件，单位为字节。
+	MinSize  int64
+	Uploader *Uploader
+	// 为 true 时仍然将引用全部替换为直链的文件复制到输出目录，
+	// 适用于 JS 等没有解析的文件也引用了这些文件的情况。
+	KeepOffloaded bool
+}
+
+// FindSiteRefs 查找 HTML 的 src、href、poster、srcset 属性和 CSS 的 url() 中引用的本地文件。
+//
+// 相对路径相对于文件 path 所在的目录，以 / 开头的路径相对于网站根目录。
+func (o *SiteOffloader) FindSiteRefs(path string, content []byte) []LocalRef {
+	text := string(content)
+	matches := [][]int{}
+	if isHTMLFile(path) {
+		matches = append(matches, TagAttrRanges(text, htmlURLAttr)...)
+		for _, attr := range TagAttrRanges(text, htmlSrcsetAttr) {
+			for _, item := range SrcsetURLRanges(text[attr[0]:attr[1]]) {
+				matches = append(matches, []int{attr[0] + item[0], attr[0] + item[1]})
+			}
+		}
+	}
+	matches = append(matches, submatchRanges(cssURL.FindAllStringSubmatchIndex(text, -1))...)
+	refs := []LocalRef{}
+	for _, match := range matches {
+		target := text[match[0]:match[1]]
+		resolved, suffix, ok := o.resolve(path, target)
+		if !ok {
+			continue
+		}
+		refs = append(refs, LocalRef{Start: match[0], End: match[1], Target: target, Path: resolved, Suffix: suffix})
+	}
+	sortRefs(refs)
+	return refs
+}
+
+// SrcsetURLRanges 按照 HTML 标准解析 srcset 属性值，返回每个候选项中 URL 的范围，忽略宽度和像素密度描述符。
+func SrcsetURLRanges(value string) [][]int {
+	ranges := [][]int{}
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' }
+	for i := 0; i < len(value); {
+		for i < len(value) && (isSpace(value[i]) || value[i] == ',') {
+			i++
+		}
+		start := i
+		for i < len(value) && !isSpace(value[i]) {
+			i++
+		}
+		end := i
+		if end == start {
+			break
+		}
+		// URL 后紧跟的逗号表示候选项结束，没有描述符。
+		if value[end-1] == ',' {
+			for end > start && value[end-1] == ',' {
+				end--
+			}
+		} else {
+			// 跳过描述符，直到不在括号内的逗号。
+			inParens := false
+			for ; i < len(value) && (inParens || value[i] != ','); i++ {
+				switch value[i] {
+				case '(':
+					inParens = true
+				case ')':
+					inParens = false
+				}
+			}
+		}
+		if end > start {
+			ranges = append(ranges, []int{start, end})
+		}
+	}
+	return ranges
+}
+
+// TagAttrRanges 在 HTML 的每个标签中查找属性，返回属性值的范围。
+func TagAttrRanges(text string, attr *regexp.Regexp) [][]int {
+	ranges := [][]int{}
+	for _, tag := range htmlTag.FindAllStringIndex(text, -1) {
+		for _, match := range submatchRanges(attr.FindAllStringSubmatchIndex(text[tag[0]:tag[1]], -1)) {
+			ranges = append(ranges, []int{tag[0] + match[0], tag[0] + match[1]})
+		}
+	}
+	return ranges
+}
+
+// submatchRanges 返回每个匹配中第一个非空的子匹配的范围。
+func submatchRanges(matches [][]int) [][]int {
+	ranges := [][]int{}
+	for _, match := range matches {
+		for i := 2; i+1 < len(match); i += 2 {
+			if match[i] >= 0 {
+				ranges = append(ranges, []int{match[i], match[i+1]})
+				break
+			}
+		}
+	}
+	return ranges
+}
+
+// resolve 解析引用，只返回位于网站目录内、满足大小要求且可以外链的文件。
+func (o *SiteOffloader) resolve(path string, target string) (string, string, bool) {
+	baseDir := filepath.Dir(path)
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") {
+		baseDir = o.Root
+		target = strings.TrimLeft(target, "/")
+	}
+	resolved, suffix, ok := ResolveLocalTarget(target, baseDir)
+	if !ok || isSiteSourceFile(resolved) {
+		return "", "", false
+	}
+	if !isInsideDir(resolved, o.Root) {
+		return "", "", false
+	}
+	stat, err := os.Stat(resolved)
+	if err != nil || stat.Size() < o.MinSize {
+		return "", "", false
+	}
+	return resolved, suffix, true
+}
+
+// Offload 处理网站目录中的全部文件，将结果写入 outDir，返回上传失败的文件数量。
+//
+// HTML 和 CSS 文件中的引用会被替换为直链，其他文件原样复制，但引用全部替换为直链的文件不再复制，
+// 除非 KeepOffloaded 为 true。Uploader.DryRun 为 true 时不写入 outDir。
+func (o *SiteOffloader) Offload(outDir string) (failed int, err error) {
+	// 其他文件在处理完全部 HTML 和 CSS 之后再复制，此时才能知道文件的引用是否全部被替换。
+	others := []string{}
+	// offloaded 记录文件的引用是否全部被替换为直链。
+	offloaded := map[string]bool{}
+	err = filepath.WalkDir(o.Root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(o.Root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(outDir, rel)
+		dryRun := o.Uploader.DryRun
+		if entry.IsDir() {
+			if path == outDir {
+				return filepath.SkipDir
+			}
+			if dryRun {
+				return nil
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		if !isHTMLFile(path) && !isCSSFile(path) {
+			others = append(others, path)
+			return nil
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		refs := o.FindSiteRefs(path, content)
+		urls, mappings, count := UploadRefs(refs, o.Uploader)
+		failed += count
+		for _, ref := range refs {
+			_, ok := urls[ref.Path]
+			if done, seen := offloaded[ref.Path]; !seen || done {
+				offloaded[ref.Path] = ok
+			}
+		}
+		for _, mapping := range mappings {
+			fmt.Fprintf(os.Stderr, "%s: %s -> %s\n", rel, mapping.Target, mapping.URL)
+		}
+		if dryRun {
+			return nil
+		}
+		return os.WriteFile(target, ReplaceRefs(content, refs, urls), 0644)
+	})
+	if err != nil || o.Uploader.DryRun {
+		return failed, err
+	}
+	for _, path := range others {
+		if offloaded[path] && !o.KeepOffloaded {
+			continue
+		}
+		rel, err := filepath.Rel(o.Root, path)
+		if err != nil {
+			return failed, err
+		}
+		if err = copyFile(path, filepath.Join(outDir, rel)); err != nil {
+			return failed, err
+		}
+	}
+	return failed, nil
+}
+
+func isHTMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm"
+}
+
+func isCSSFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".css"
+}
+
+// isSiteSourceFile 判断文件是否需要与页面同源，这类文件不会被上传。
+func isSiteSourceFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm", ".css", ".js", ".mjs", ".json", ".xml", ".map", ".webmanifest":
+		return true
+	}
+	return false
+}
+
+// copyFile 复制文件，保留修改时间。
+func copyFile(src string, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	if stat, err := in.Stat(); err == nil {
+		os.Chtimes(dst, stat.ModTime(), stat.ModTime())
+	}
+	return nil
+}
+
+// ParseSize 解析文件大小，支持 K、M、G 后缀，例如 512K、1M。
+func ParseSize(text string) (int64, error) {
+	text = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(text)), "B")
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(text, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(text, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(text, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		text = text[:len(text)-1]
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, err
+	}
+	return int64(value * float64(multiplier)), nil
+}
+
+func cmdOffload(args []string) int {
+	flags := newFlagSet("offload")
+	out := flags.String("o", "", "输出目录，必填")
+	minSize := flags.String("min-size", "1M", "只上传不小于该大小的文件，支持 K、M、G 后缀")
+	manifestPath := flags.String("manifest", DataPath("manifest.json"), "清单文件，未变化的文件不会重新上传")
+	dryRun := flags.Bool("dry-run", false, "不上传文件也不写入输出目录，只输出将要替换的引用")
+	keepOffloaded := flags.Bool("keep-offloaded", false, "仍然将已经替换为直链的文件复制到输出目录")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 1 || *out == "" {
+		flags.Usage()
+		return ExitUsage
+	}
+	size, err := ParseSize(*minSize)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 无法解析文件大小", *minSize)
+		return ExitUsage
+	}
+	root, err := filepath.Abs(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	if stat, err := os.Stat(root); err != nil || !stat.IsDir() {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 网站目录不存在", root)
+		return ExitNotFound
+	}
+	outDir, err := filepath.Abs(*out)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	manifest, err := LoadManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	uploader := &Uploader{DryRun: *dryRun, Manifest: manifest}
+	if !*dryRun {
+		userInfo, code := requireUserInfo()
+		if userInfo == nil {
+			return code
+		}
+		uploader.UserInfo = userInfo
+	}
+	offloader := &SiteOffloader{Root: root, MinSize: size, Uploader: uploader, KeepOffloaded: *keepOffloaded}
+	failed, err := offloader.Offload(outDir)
+	if !*dryRun {
+		if err := manifest.Save(); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	if failed > 0 {
+		return ExitUpload
+	}
+	return ExitOK
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestFindSiteRefs(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "media"), 0755)
+	os.WriteFile(filepath.Join(root, "media", "big.mp4"), make([]byte, 2048), 0644)
+	os.WriteFile(filepath.Join(root, "media", "small.png"), make([]byte, 10), 0644)
+	page := filepath.Join(root, "index.html")
+	html := `<video src="media/big.mp4" poster='/media/big.mp4'></video>` +
+		`<img src="media/small.png" srcset="media/big.mp4 2x, media/small.png 1x">` +
+		`<a href="https://example.com/big.mp4">x</a>` +
+		`<div style="background:url('media/big.mp4?v=1')"></div>`
+	offloader := &SiteOffloader{Root: root, MinSize: 1024}
+	refs := offloader.FindSiteRefs(page, []byte(html))
+	if len(refs) != 4 {
+		t.Fatalf("%#v", refs)
+	}
+	urls := map[string]string{filepath.Join(root, "media", "big.mp4"): "https://files.imdodo.com/dodo/big.mp4"}
+	want := `<video src="https://files.imdodo.com/dodo/big.mp4" poster='https://files.imdodo.com/dodo/big.mp4'></video>` +
+		`<img src="media/small.png" srcset="https://files.imdodo.com/dodo/big.mp4 2x, media/small.png 1x">` +
+		`<a href="https://example.com/big.mp4">x</a>` +
+		`<div style="background:url('https://files.imdodo.com/dodo/big.mp4?v=1')"></div>`
+	if got := string(ReplaceRefs([]byte(html), refs, urls)); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for text, want := range map[string]int64{"1M": 1 << 20, "512k": 512 << 10, "1.5MB": 3 << 19, "100": 100} {
+		if got, err := ParseSize(text); err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v, want %d", text, got, err, want)
+		}
+	}
+}
+
+func TestSrcsetURLRanges(t *testing.T) {
+	for value, want := range map[string][]string{
+		"a.jpg 480w,b.jpg 800w":               {"a.jpg", "b.jpg"},
+		"a.jpg, b.jpg 2x":                     {"a.jpg", "b.jpg"},
+		" a.jpg 1x , b.jpg 2x ,":              {"a.jpg", "b.jpg"},
+		"a,b.jpg 1x, c.jpg":                   {"a,b.jpg", "c.jpg"},
+		"a.jpg (max-width: 1px, 2) 1x, b.jpg": {"a.jpg", "b.jpg"},
+	} {
+		got := []string{}
+		for _, item := range SrcsetURLRanges(value) {
+			got = append(got, value[item[0]:item[1]])
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("SrcsetURLRanges(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestSiteResolve(t *testing.T) {
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, "..assets"), 0755)
+	os.WriteFile(filepath.Join(root, "..assets", "x.png"), []byte("x"), 0644)
+	offloader := &SiteOffloader{Root: root}
+	if _, _, ok := offloader.resolve(filepath.Join(root, "index.html"), "..assets/x.png"); !ok {
+		t.Fatal("..assets/x.png should resolve")
+	}
+	if _, _, ok := offloader.resolve(filepath.Join(root, "index.html"), "../x.png"); ok {
+		t.Fatal("../x.png is outside the site")
+	}
+}
+
+func TestOffloadSkipsOffloaded(t *testing.T) {
+	root := t.TempDir()
+	big := filepath.Join(root, "big.mp4")
+	os.WriteFile(big, make([]byte, 2048), 0644)
+	os.WriteFile(filepath.Join(root, "small.png"), make([]byte, 10), 0644)
+	os.WriteFile(filepath.Join(root, "index.html"), []byte(`<video src="big.mp4" poster="small.png"></video>`), 0644)
+	manifest, err := LoadManifest(filepath.Join(t.TempDir(), "manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stat, _ := os.Stat(big)
+	manifest.Put(&UploadResult{Path: big, MD5: "abc", URL: "https://files.imdodo.com/dodo/abc.mp4"}, stat)
+	for _, keep := range []bool{false, true} {
+		out := t.TempDir()
+		offloader := &SiteOffloader{Root: root, MinSize: 1024, Uploader: &Uploader{Manifest: manifest}, KeepOffloaded: keep}
+		if failed, err := offloader.Offload(out); err != nil || failed != 0 {
+			t.Fatal(failed, err)
+		}
+		if _, err := os.Stat(filepath.Join(out, "small.png")); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(out, "big.mp4")); (err == nil) != keep {
+			t.Fatalf("keep %v: %v", keep, err)
+		}
+	}
+}
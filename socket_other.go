@@ -0,0 +1,10 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "net"
+
+// listenUnix 监听 Unix 套接字，当前平台不支持 umask，由调用方修改权限。
+func listenUnix(socket string) (net.Listener, error) {
+	return net.Listen("unix", socket)
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"net"
+	"syscall"
+)
+
+// listenUnix 监听 Unix 套接字，创建时使用 0600 权限，其他用户在任何时刻都无法连接。
+//
+// umask 对整个进程生效，因此只应在启动时、其他 goroutine 创建文件之前调用。
+func listenUnix(socket string) (net.Listener, error) {
+	old := syscall.Umask(0177)
+	defer syscall.Umask(old)
+	return net.Listen("unix", socket)
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/iuroc/gododo/dodo"
+)
+
+// SyncStateFile 默认的同步状态文件名，位于同步的目录中。
+const SyncStateFile = ".gododo-sync.json"
+
+// SyncEntry 同步状态中的单个文件，Path 为相对于同步目录、以 / 分隔的路径。
+type SyncEntry struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+	MD5     string    `json:"md5"`
+	URL     string    `json:"url"`
+}
+
+// SyncState 目录的同步状态，记录每个文件的大小、修改时间、MD5 和直链。
+type SyncState struct {
+	path  string
+	Time  time.Time             `json:"time"`
+	Files map[string]*SyncEntry `json:"files"`
+}
+
+// LoadSyncState 读取同步状态文件，文件不存在时返回空状态。
+func LoadSyncState(path string) (*SyncState, error) {
+	state := &SyncState{path: path, Files: map[string]*SyncEntry{}}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	} else if err == nil {
+		if err = json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	if state.Files == nil {
+		state.Files = map[string]*SyncEntry{}
+	}
+	return state, nil
+}
+
+// Save 写入临时文件后替换同步状态文件，避免中断时留下不完整的文件。
+func (s *SyncState) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+	temp := s.path + ".tmp"
+	if err = os.WriteFile(temp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(temp, s.path)
+}
+
+// Sorted 返回按路径排序的文件。
+func (s *SyncState) Sorted() []*SyncEntry {
+	entries := make([]*SyncEntry, 0, len(s.Files))
+	for _, entry := range s.Files {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
+	return entries
+}
+
+// SyncReport 一次同步中各类文件的相对路径。
+type SyncReport struct {
+	Uploaded  []string
+	Unchanged []string
+	// 新路径到旧路径，内容和扩展名与同步状态中的其他文件相同，无需上传。
+	Renamed map[string]string
+	Removed []string
+	Failed  []string
+}
+
+// Syncer 增量同步目录，只上传新增或修改的文件。
+type Syncer struct {
+	Dir   string
+	State *SyncState
+	// 上传文件，返回上传结果。
+	Publish func(work *dodo.UploadWork) (*UploadResult, error)
+	// 不需要同步的文件的绝对路径，例如位于同步目录中的索引。
+	Ignore []string
+}
+
+// Sync 扫描目录并更新同步状态，不会保存状态文件。
+//
+// 无法读取的文件和目录记录到 Failed 中，状态中已有的记录保持不变。
+// 大小和修改时间未变化的文件直接使用状态中的直链。其他文件计算 MD5，与状态中已有文件的 MD5
+// 和扩展名相同时视为重命名，直接使用已有的直链，否则上传。DoDo 会根据 MD5 跳过已经上传过的文件。
+func (s *Syncer) Sync() (*SyncReport, error) {
+	root, err := filepath.Abs(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	report := &SyncReport{Renamed: map[string]string{}}
+	byMD5 := map[string]*SyncEntry{}
+	for _, entry := range s.State.Files {
+		byMD5[entry.MD5+path.Ext(entry.Path)] = entry
+	}
+	files := map[string]*SyncEntry{}
+	// keep 保留路径 rel 及其子路径在状态中已有的记录。
+	keep := func(rel string) {
+		for oldPath, old := range s.State.Files {
+			if oldPath == rel || strings.HasPrefix(oldPath, rel+"/") {
+				files[oldPath] = old
+			}
+		}
+	}
+	// fail 输出无法读取的路径并保留已有的记录。
+	fail := func(filePath string, err error) {
+		rel, relErr := filepath.Rel(root, filePath)
+		if relErr != nil {
+			return
+		}
+		rel = filepath.ToSlash(rel)
+		fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", rel, err)
+		report.Failed = append(report.Failed, rel)
+		keep(rel)
+	}
+	err = filepath.WalkDir(root, func(filePath string, dirEntry fs.DirEntry, err error) error {
+		if err != nil {
+			if filePath == root {
+				return err
+			}
+			// 扫描过程中被删除的文件按删除处理。
+			if os.IsNotExist(err) {
+				return nil
+			}
+			fail(filePath, err)
+			return nil
+		}
+		if strings.HasPrefix(dirEntry.Name(), ".") && filePath != root {
+			if dirEntry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !dirEntry.Type().IsRegular() || ignoredName(dirEntry.Name()) {
+			return nil
+		}
+		for _, ignore := range s.Ignore {
+			if filePath == ignore {
+				return nil
+			}
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			if !os.IsNotExist(err) {
+				fail(filePath, err)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(root, filePath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if old := s.State.Files[rel]; old != nil && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+			files[rel] = old
+			report.Unchanged = append(report.Unchanged, rel)
+			return nil
+		}
+		work, err := dodo.NewUploadWork(filePath, "", "")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", rel, err)
+			report.Failed = append(report.Failed, rel)
+			return nil
+		}
+		entry := &SyncEntry{Path: rel, Size: info.Size(), ModTime: info.ModTime(), MD5: work.MD5}
+		if old := byMD5[work.MD5+work.Ext]; old != nil {
+			entry.URL = old.URL
+			if old.Path != rel {
+				report.Renamed[rel] = old.Path
+			} else {
+				// 只有修改时间变化。
+				report.Unchanged = append(report.Unchanged, rel)
+			}
+			files[rel] = entry
+			return nil
+		}
+		result, err := s.Publish(work)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "❗ 错误: %s: %s\n", rel, err)
+			report.Failed = append(report.Failed, rel)
+			// 保留旧的记录，下次同步时重试。
+			if old := s.State.Files[rel]; old != nil {
+				files[rel] = old
+			}
+			return nil
+		}
+		entry.URL = result.URL
+		files[rel] = entry
+		report.Uploaded = append(report.Uploaded, rel)
+		fmt.Fprintf(os.Stderr, "%s -> %s\n", rel, entry.URL)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for rel := range s.State.Files {
+		if files[rel] == nil {
+			report.Removed = append(report.Removed, rel)
+		}
+	}
+	sort.Strings(report.Removed)
+	s.State.Files = files
+	s.State.Time = time.Now()
+	return report, nil
+}
+
+// SyncIndex 同步目录的索引。
+type SyncIndex struct {
+	Time  time.Time    `json:"time"`
+	Size  int64        `json:"size"`
+	Files []*SyncEntry `json:"files"`
+}
+
+// Index 返回同步状态的索引。
+func (s *SyncState) Index() *SyncIndex {
+	index := &SyncIndex{Time: s.Time, Files: s.Sorted()}
+	for _, entry := range index.Files {
+		index.Size += entry.Size
+	}
+	return index
+}
+
+// WriteJSON 以 JSON 格式输出索引。
+func (index *SyncIndex) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(index)
+}
+
+var syncIndexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"size": HumanSize,
+	"base": path.Base,
+}).Parse(`<!doctype html>
+<html lang="zh-CN">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+  body { margin: 0 auto; max-width: 960px; padding: 24px 16px; font: 14px/1.6 system-ui, -apple-system, "Segoe UI", "Microsoft YaHei", sans-serif; color: #222; }
+  h1 { font-size: 20px; }
+  summary { font-weight: 600; cursor: pointer; padding: 4px 0; }
+  table { width: 100%; border-collapse: collapse; margin: 4px 0 12px; }
+  td { padding: 4px 8px; border-bottom: 1px solid #eee; }
+  td.size, td.time { color: #666; white-space: nowrap; text-align: right; width: 1%; }
+  a { color: #1769e0; text-decoration: none; overflow-wrap: anywhere; }
+  input { width: 100%; box-sizing: border-box; font: inherit; padding: 6px 8px; margin-bottom: 12px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{len .Index.Files}} 个文件，共 {{size .Index.Size}}，更新于 {{.Index.Time.Format "2006-01-02 15:04:05"}}</p>
+<input type="search" placeholder="搜索文件" oninput="filter(this.value)">
+{{range .Dirs}}<details open>
+<summary>{{if .Name}}{{.Name}}/{{else}}/{{end}}</summary>
+<table>
+{{range .Files}}<tr data-path="{{.Path}}"><td><a href="{{.URL}}">{{base .Path}}</a></td><td class="size">{{size .Size}}</td><td class="time">{{.ModTime.Format "2006-01-02 15:04"}}</td></tr>
+{{end}}</table>
+</details>
+{{end}}<script>
+function filter(text) {
+  text = text.trim().toLowerCase();
+  for (const row of document.querySelectorAll("tr")) {
+    row.hidden = !row.dataset.path.toLowerCase().includes(text);
+  }
+}
+</script>
+</body>
+</html>
+`))
+
+type syncIndexDir struct {
+	Name  string
+	Files []*SyncEntry
+}
+
+// WriteHTML 输出可以浏览的 HTML 索引页，文件按目录分组。
+func (index *SyncIndex) WriteHTML(w io.Writer, title string) error {
+	dirs := []*syncIndexDir{}
+	byName := map[string]*syncIndexDir{}
+	for _, entry := range index.Files {
+		name := path.Dir(entry.Path)
+		if name == "." {
+			name = ""
+		}
+		dir := byName[name]
+		if dir == nil {
+			dir = &syncIndexDir{Name: name}
+			byName[name] = dir
+			dirs = append(dirs, dir)
+		}
+		dir.Files = append(dir.Files, entry)
+	}
+	sort.SliceStable(dirs, func(i, j int) bool {
+		return dirs[i].Name < dirs[j].Name
+	})
+	return syncIndexTemplate.Execute(w, map[string]any{"Title": title, "Index": index, "Dirs": dirs})
+}
+
+func cmdSync(args []string) int {
+	flags := newFlagSet("sync")
+	statePath := flags.String("state", "", "同步状态文件，默认为目录中的 "+SyncStateFile)
+	indexPath := flags.String("index", "-", "JSON 索引输出路径，- 表示标准输出，为空时不输出")
+	htmlPath := flags.String("html", "", "HTML 索引页输出路径")
+	title := flags.String("title", "", "HTML 索引页标题，默认为目录名")
+	indexOnly := flags.Bool("index-only", false, "不扫描目录，只根据同步状态重新生成索引")
+	dryRun := flags.Bool("dry-run", false, "不上传文件，也不保存同步状态，只计算直链")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	dir := flags.Arg(0)
+	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 目录不存在:", dir)
+		return ExitNotFound
+	}
+	if *statePath == "" {
+		*statePath = filepath.Join(dir, SyncStateFile)
+	}
+	if *title == "" {
+		absDir, _ := filepath.Abs(dir)
+		*title = filepath.Base(absDir)
+	}
+	state, err := LoadSyncState(*statePath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	code := ExitOK
+	if !*indexOnly {
+		syncer := &Syncer{Dir: dir, State: state}
+		for _, output := range []string{*statePath, *indexPath, *htmlPath} {
+			if absPath, err := filepath.Abs(output); err == nil && output != "" && output != "-" {
+				syncer.Ignore = append(syncer.Ignore, absPath)
+			}
+		}
+		if *dryRun {
+			syncer.Publish = func(work *dodo.UploadWork) (*UploadResult, error) {
+				return NewUploadResult(work, work.ResourceURL(), false), nil
+			}
+		} else {
+			userInfo, code := requireUserInfo()
+			if userInfo == nil {
+				return code
+			}
+			syncer.Publish = func(work *dodo.UploadWork) (*UploadResult, error) {
+				work.Token, work.UID = userInfo.Token, userInfo.UID
+				return publishWork(context.Background(), work, "")
+			}
+		}
+		report, err := syncer.Sync()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+		renamed := []string{}
+		for to := range report.Renamed {
+			renamed = append(renamed, to)
+		}
+		sort.Strings(renamed)
+		for _, to := range renamed {
+			fmt.Fprintf(os.Stderr, "%s -> %s (重命名)\n", report.Renamed[to], to)
+		}
+		for _, rel := range report.Removed {
+			fmt.Fprintf(os.Stderr, "%s (已删除)\n", rel)
+		}
+		fmt.Fprintf(os.Stderr, "上传 %d 个，未变化 %d 个，重命名 %d 个，删除 %d 个，失败 %d 个\n",
+			len(report.Uploaded), len(report.Unchanged), len(report.Renamed), len(report.Removed), len(report.Failed))
+		if len(report.Failed) > 0 {
+			code = ExitUpload
+		}
+		if !*dryRun {
+			if err = state.Save(); err != nil {
+				fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+				return ExitError
+			}
+		}
+	}
+	index := state.Index()
+	if *indexPath != "" {
+		if err = writeFeedFile(*indexPath, index.WriteJSON); err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+	}
+	if *htmlPath != "" {
+		err = writeFeedFile(*htmlPath, func(w io.Writer) error {
+			return index.WriteHTML(w, *title)
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+			return ExitError
+		}
+	}
+	return code
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iuroc/gododo/dodo"
+)
+
+func TestSyncer(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.zip", "a")
+	write("sub/b.txt", "b")
+	write(".hidden/c.txt", "c")
+	write("index.html", "<html>")
+	statePath := filepath.Join(dir, SyncStateFile)
+	published := []string{}
+	sync := func() *SyncReport {
+		state, err := LoadSyncState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		syncer := &Syncer{Dir: dir, State: state, Ignore: []string{filepath.Join(dir, "index.html")}}
+		syncer.Publish = func(work *dodo.UploadWork) (*UploadResult, error) {
+			published = append(published, work.Base)
+			return NewUploadResult(work, work.ResourceURL(), false), nil
+		}
+		report, err := syncer.Sync()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = state.Save(); err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	report := sync()
+	if want := []string{"a.zip", "sub/b.txt"}; !reflect.DeepEqual(report.Uploaded, want) {
+		t.Errorf("first sync uploaded %v, want %v", report.Uploaded, want)
+	}
+	report = sync()
+	if len(report.Uploaded) != 0 || len(report.Unchanged) != 2 {
+		t.Errorf("second sync = %+v", report)
+	}
+
+	os.Rename(filepath.Join(dir, "a.zip"), filepath.Join(dir, "sub", "a-1.0.zip"))
+	write("sub/b.txt", "b2")
+	os.Chtimes(filepath.Join(dir, "sub", "b.txt"), time.Now(), time.Now().Add(time.Minute))
+	report = sync()
+	if want := map[string]string{"sub/a-1.0.zip": "a.zip"}; !reflect.DeepEqual(report.Renamed, want) {
+		t.Errorf("renamed = %v, want %v", report.Renamed, want)
+	}
+	if !reflect.DeepEqual(report.Uploaded, []string{"sub/b.txt"}) || !reflect.DeepEqual(report.Removed, []string{"a.zip"}) {
+		t.Errorf("third sync = %+v", report)
+	}
+	if want := []string{"a.zip", "b.txt", "b.txt"}; !reflect.DeepEqual(published, want) {
+		t.Errorf("published %v, want %v", published, want)
+	}
+
+	state, _ := LoadSyncState(statePath)
+	index := state.Index()
+	if len(index.Files) != 2 || index.Files[0].Path != "sub/a-1.0.zip" || index.Size != 3 ||
+		index.Files[0].URL != "https://files.imdodo.com/dodo/0cc175b9c0f1b6a831c399e269772661.zip" {
+		t.Errorf("index = %+v", index.Files)
+	}
+	var html strings.Builder
+	if err := index.WriteHTML(&html, "release <1>"); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"<title>release &lt;1&gt;</title>", "<summary>sub/</summary>", `<a href="https://files.imdodo.com/dodo/0cc175b9c0f1b6a831c399e269772661.zip">a-1.0.zip</a>`} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("HTML index does not contain %s", want)
+		}
+	}
+}
+
+func TestSyncerUnreadableDir(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Getuid() == 0 {
+		t.Skip("需要以非 root 用户在类 Unix 系统上运行")
+	}
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	os.WriteFile(filepath.Join(dir, "a.txt"), []byte("a"), 0644)
+	entry := &SyncEntry{Path: "sub/b.txt", Size: 1, MD5: "92eb5ffee6ae2fec3ad71c777531578f", URL: "https://files.imdodo.com/dodo/92eb5ffee6ae2fec3ad71c777531578f.txt"}
+	state := &SyncState{Files: map[string]*SyncEntry{entry.Path: entry}}
+	os.Chmod(filepath.Join(dir, "sub"), 0)
+	defer os.Chmod(filepath.Join(dir, "sub"), 0755)
+	syncer := &Syncer{Dir: dir, State: state, Publish: func(work *dodo.UploadWork) (*UploadResult, error) {
+		return NewUploadResult(work, work.ResourceURL(), false), nil
+	}}
+	report, err := syncer.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Failed, []string{"sub"}) || len(report.Removed) != 0 || state.Files["sub/b.txt"] != entry {
+		t.Errorf("report = %+v, files = %v", report, state.Files)
+	}
+	if !reflect.DeepEqual(report.Uploaded, []string{"a.txt"}) {
+		t.Errorf("uploaded %v", report.Uploaded)
+	}
+}
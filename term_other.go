@@ -0,0 +1,19 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// 当前平台不支持 raw 模式，交互模式使用系统自带的行输入。
+
+func isTerminal(fd int) bool {
+	return false
+}
+
+func makeRaw(fd int) (restore func(), err error) {
+	return nil, errors.New("raw mode is not supported on this platform")
+}
+
+func terminalWidth(fd int) int {
+	return 80
+}
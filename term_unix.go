@@ -0,0 +1,54 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// isTerminal 判断文件描述符是否为终端。
+func isTerminal(fd int) bool {
+	var termios syscall.Termios
+	return ioctl(fd, ioctlGetTermios, unsafe.Pointer(&termios)) == nil
+}
+
+// makeRaw 将终端切换为 raw 模式，返回用于恢复原有模式的函数。
+func makeRaw(fd int) (restore func(), err error) {
+	var old syscall.Termios
+	if err = ioctl(fd, ioctlGetTermios, unsafe.Pointer(&old)); err != nil {
+		return nil, err
+	}
+	raw := old
+	raw.Iflag &^= syscall.IGNBRK | syscall.BRKINT | syscall.PARMRK | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR | syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ECHO | syscall.ECHONL | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	raw.Cflag &^= syscall.CSIZE | syscall.PARENB
+	raw.Cflag |= syscall.CS8
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err = ioctl(fd, ioctlSetTermios, unsafe.Pointer(&raw)); err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(fd, ioctlSetTermios, unsafe.Pointer(&old))
+	}, nil
+}
+
+// terminalWidth 返回终端的列数，获取失败时返回 80。
+func terminalWidth(fd int) int {
+	var size struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&size)); err != nil || size.Col == 0 {
+		return 80
+	}
+	return int(size.Col)
+}
+
+func ioctl(fd int, request uintptr, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
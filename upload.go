@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iuroc/gododo/dodo"
+)
+
+// 数据文件名，位于 [DataPath] 指定的目录。
+const (
+	UserInfoFile = "userInfo.json"
+	CookiesFile  = "cookies.txt"
+	HistoryFile  = "history.jsonl"
+)
+
+// DataPath 返回数据文件的路径。数据目录由环境变量 GODODO_HOME 指定，默认为当前目录。
+func DataPath(name string) string {
+	home := os.Getenv("GODODO_HOME")
+	if home == "" {
+		home = "."
+	}
+	return filepath.Join(home, name)
+}
+
+// Profile 当前使用的账号名称，由环境变量 GODODO_PROFILE 指定，为空时使用默认账号。
+var Profile = os.Getenv("GODODO_PROFILE")
+
+// CheckProfile 检查账号名称，账号名称用作文件名，不能包含路径分隔符或 ..。
+func CheckProfile(name string) error {
+	if strings.ContainsAny(name, `/\`) || strings.Contains(name, "..") || strings.ContainsRune(name, 0) {
+		return fmt.Errorf("账号名称不能包含路径分隔符或 ..: %q", name)
+	}
+	return nil
+}
+
+// UserInfoPath 返回当前账号的用户信息文件路径。默认账号使用 userInfo.json，其他账号保存在 profiles 目录。
+func UserInfoPath() string {
+	if Profile == "" || Profile == "default" {
+		return DataPath(UserInfoFile)
+	}
+	return DataPath(filepath.Join("profiles", Profile+".json"))
+}
+
+// ListProfiles 返回全部已保存的账号名称，包括默认账号。
+func ListProfiles() []string {
+	profiles := []string{}
+	if _, err := os.Stat(DataPath(UserInfoFile)); err == nil {
+		profiles = append(profiles, "default")
+	}
+	matches, _ := filepath.Glob(DataPath(filepath.Join("profiles", "*.json")))
+	for _, match := range matches {
+		profiles = append(profiles, strings.TrimSuffix(filepath.Base(match), ".json"))
+	}
+	return profiles
+}
+
+// UploadResult 单个文件的上传结果。
+type UploadResult struct {
+	Path string `json:"path"`
+	Base string `json:"name"`
+	Ext  string `json:"ext"`
+	MD5  string `json:"md5"`
+	Size int64  `json:"size"`
+	URL  string `json:"url"`
+	// 为 true 表示文件已经被上传过，本次没有重复上传。
+	Cached bool      `json:"cached"`
+	Time   time.Time `json:"time"`
+	// 直链二维码 PNG 的路径，仅在保存二维码时存在。
+	QRCode string `json:"qrcode,omitempty"`
+}
+
+// UploadFile 上传文件并返回文件直链，已上传过的文件直接读取历史记录。
+//
+// 上传成功后，结果会追加到上传历史，然后在后台执行 [HooksFile] 中配置的钩子，上传失败时执行失败钩子。
+func UploadFile(path string, userInfo *UserInfo) (*UploadResult, error) {
+	return UploadFileContext(context.Background(), path, userInfo, nil)
+}
+
+// UploadFileContext 与 [UploadFile] 相同，ctx 取消后停止上传，progress 不为空时报告已发送的字节数和文件大小。
+func UploadFileContext(ctx context.Context, path string, userInfo *UserInfo, progress func(sent int64, total int64)) (*UploadResult, error) {
+	work, err := dodo.NewUploadWork(path, userInfo.Token, userInfo.UID)
+	if err != nil {
+		uploadHooks(path, nil, err)
+		return nil, err
+	}
+	work.Progress = progress
+	return publishWork(ctx, work, "")
+}
+
+// UploadReader 将 r 的内容保存为名为 name 的临时文件后上传，上传结果的 Path 为 name，其他参数与 [UploadFileContext] 相同。
+//
+// 文件需要先计算 MD5 才能上传，因此内容会暂存到磁盘，而不是内存。
+func UploadReader(ctx context.Context, name string, r io.Reader, userInfo *UserInfo, progress func(sent int64, total int64)) (*UploadResult, error) {
+	dir, err := os.MkdirTemp("", "gododo-upload-")
+	if err != nil {
+		uploadHooks(name, nil, err)
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	work, err := spoolWork(dir, name, r, userInfo)
+	if err != nil {
+		uploadHooks(name, nil, err)
+		return nil, err
+	}
+	work.Progress = progress
+	return publishWork(ctx, work, name)
+}
+
+// spoolWork 将 r 的内容保存到 dir 中名为 name 的文件，返回该文件的上传任务。
+func spoolWork(dir string, name string, r io.Reader, userInfo *UserInfo) (*dodo.UploadWork, error) {
+	path := filepath.Join(dir, SafeFileName(name))
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return dodo.NewUploadWork(path, userInfo.Token, userInfo.UID)
+}
+
+// SafeFileName 返回路径中的文件名，去除目录部分，文件名为空时返回 file。
+func SafeFileName(name string) string {
+	name = filepath.Base(filepath.FromSlash(strings.ReplaceAll(name, "\\", "/")))
+	if name == "." || name == string(filepath.Separator) || name == ".." {
+		return "file"
+	}
+	return name
+}
+
+// publishWork 发布上传任务、追加上传历史并执行钩子，path 不为空时替换上传结果中的路径。
+func publishWork(ctx context.Context, work *dodo.UploadWork, path string) (*UploadResult, error) {
+	resourceURL, cached, err := work.PublishContext(ctx)
+	if err != nil {
+		if path == "" {
+			path = work.Path
+		}
+		uploadHooks(path, nil, err)
+		return nil, err
+	}
+	result := NewUploadResult(work, resourceURL, cached)
+	if path != "" {
+		result.Path = path
+	}
+	if err = AppendHistory(result); err != nil {
+		fmt.Fprintln(os.Stderr, "⚠️ 上传历史写入失败:", err)
+	}
+	uploadHooks(path, result, nil)
+	return result, nil
+}
+
+// NewUploadResult 根据上传任务创建上传结果。
+func NewUploadResult(work *dodo.UploadWork, resourceURL string, cached bool) *UploadResult {
+	path, err := filepath.Abs(work.Path)
+	if err != nil {
+		path = work.Path
+	}
+	return &UploadResult{
+		Path:   path,
+		Base:   work.Base,
+		Ext:    work.Ext,
+		MD5:    work.MD5,
+		Size:   work.Stat.Size(),
+		URL:    resourceURL,
+		Cached: cached,
+		Time:   time.Now(),
+	}
+}
+
+// Uploader 批量上传文件，同一次运行中相同的文件只上传一次。可以在多个 goroutine 中同时使用。
+type Uploader struct {
+	UserInfo *UserInfo
+	// 为 true 时只计算 MD5 和直链，不上传文件，也不需要登录。
+	DryRun bool
+	// 不为空时，大小和修改时间未变化的文件直接使用清单中的直链，上传结果也会记录到清单。
+	Manifest *Manifest
+	cache    map[string]*UploadResult
+	mu       sync.Mutex
+}
+
+// Upload 上传文件并返回上传结果，已上传过的文件直接返回缓存的结果。
+func (u *Uploader) Upload(path string) (*UploadResult, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	u.mu.Lock()
+	result, ok := u.cache[absPath]
+	u.mu.Unlock()
+	if ok {
+		return result, nil
+	}
+	stat, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	var entry *ManifestEntry
+	if u.Manifest != nil {
+		u.mu.Lock()
+		entry, ok = u.Manifest.Lookup(absPath, stat)
+		u.mu.Unlock()
+	}
+	if ok {
+		result = &UploadResult{
+			Path:   absPath,
+			Base:   filepath.Base(absPath),
+			Ext:    filepath.Ext(absPath),
+			MD5:    entry.MD5,
+			Size:   entry.Size,
+			URL:    entry.URL,
+			Cached: true,
+			Time:   time.Now(),
+		}
+	} else if u.DryRun {
+		work, err := dodo.NewUploadWork(absPath, "", "")
+		if err != nil {
+			return nil, err
+		}
+		result = NewUploadResult(work, work.ResourceURL(), false)
+	} else {
+		result, err = UploadFile(absPath, u.UserInfo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.Manifest != nil && !u.DryRun {
+		u.Manifest.Put(result, stat)
+	}
+	if u.cache == nil {
+		u.cache = map[string]*UploadResult{}
+	}
+	u.cache[absPath] = result
+	return result, nil
+}
+
+var historyMu sync.Mutex
+
+// AppendHistory 以 JSON Lines 格式追加上传结果到上传历史。
+func AppendHistory(result *UploadResult) error {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	file, err := os.OpenFile(DataPath(HistoryFile), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(append(data, '\n'))
+	return err
+}
+
+// ReadHistory 读取全部上传历史，按上传时间从早到晚排列。
+func ReadHistory() ([]*UploadResult, error) {
+	file, err := os.Open(DataPath(HistoryFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	results := []*UploadResult{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var result UploadResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			continue
+		}
+		results = append(results, &result)
+	}
+	return results, scanner.Err()
+}
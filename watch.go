@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Watcher 轮询目录，找出新增或修改后大小和修改时间不再变化的文件。
+//
+// 使用轮询而不是系统的文件通知，在网络驱动器和各个平台上表现一致。
+type Watcher struct {
+	Dir string
+	// 为 true 时同时监视子目录。
+	Recursive bool
+	// 文件的大小和修改时间连续 Settle 次扫描保持不变时，才认为已经写入完成。
+	Settle int
+	// 不需要上传的文件的绝对路径，例如位于监视目录中的日志和清单。
+	Ignore []string
+	// 每个文件最多尝试上传的次数，超过后直到文件再次修改才重新上传。为 0 时不限制次数。
+	Retries int
+	files   map[string]*watchedFile
+}
+
+type watchedFile struct {
+	size     int64
+	modTime  time.Time
+	stable   int
+	done     bool
+	attempts int
+	nextTry  time.Time
+}
+
+// partialName 判断文件是否为隐藏文件或下载、编辑器产生的临时文件。
+func partialName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, suffix := range []string{".tmp", ".temp", ".part", ".partial", ".crdownload", ".download", ".swp", "~"} {
+		if strings.HasSuffix(lower, suffix) {
+			return true
+		}
+	}
+	return strings.HasPrefix(name, ".") || strings.HasPrefix(name, "~$")
+}
+
+// Init 记录目录中已有的文件。existing 为 false 时，已有的文件在修改前不会被上传。
+func (w *Watcher) Init(existing bool) error {
+	w.files = map[string]*watchedFile{}
+	if existing {
+		return nil
+	}
+	return w.walk(func(path string, info fs.FileInfo) {
+		w.files[path] = &watchedFile{size: info.Size(), modTime: info.ModTime(), done: true}
+	})
+}
+
+// Scan 扫描一次目录，返回已经写入完成、等待上传的文件，按路径排序。
+//
+// 返回的文件在调用 [Watcher.Done] 之前，下次扫描仍然会返回，调用 [Watcher.Failed] 后到达重试时间才会返回。
+func (w *Watcher) Scan() ([]string, error) {
+	if w.files == nil {
+		w.files = map[string]*watchedFile{}
+	}
+	now := time.Now()
+	seen := map[string]bool{}
+	ready := []string{}
+	err := w.walk(func(path string, info fs.FileInfo) {
+		seen[path] = true
+		file := w.files[path]
+		if file == nil || file.size != info.Size() || !file.modTime.Equal(info.ModTime()) {
+			w.files[path] = &watchedFile{size: info.Size(), modTime: info.ModTime()}
+			return
+		}
+		if file.done || file.nextTry.After(now) {
+			return
+		}
+		if file.stable < w.Settle {
+			file.stable++
+		}
+		if file.stable >= w.Settle {
+			ready = append(ready, path)
+		}
+	})
+	for path := range w.files {
+		if !seen[path] {
+			delete(w.files, path)
+		}
+	}
+	sort.Strings(ready)
+	return ready, err
+}
+
+// Done 标记文件已经上传，文件再次修改后才会重新上传。
+func (w *Watcher) Done(path string) {
+	if file := w.files[path]; file != nil {
+		file.done = true
+	}
+}
+
+// Failed 记录文件上传失败，按 30 秒、1 分钟、2 分钟……的间隔重试。
+// 失败次数达到 Retries 后返回 false，文件再次修改后才会重新上传。
+func (w *Watcher) Failed(path string) bool {
+	file := w.files[path]
+	if file == nil {
+		return true
+	}
+	file.attempts++
+	if w.Retries > 0 && file.attempts >= w.Retries {
+		file.done = true
+		return false
+	}
+	file.nextTry = time.Now().Add(retryDelay(file.attempts))
+	return true
+}
+
+func (w *Watcher) walk(fn func(path string, info fs.FileInfo)) error {
+	root, err := filepath.Abs(w.Dir)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// 扫描过程中被删除的文件。
+			if os.IsNotExist(err) && path != root {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			if path != root && (!w.Recursive || strings.HasPrefix(entry.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.Type().IsRegular() || ignoredName(entry.Name()) || partialName(entry.Name()) {
+			return nil
+		}
+		for _, ignore := range w.Ignore {
+			if path == ignore {
+				return nil
+			}
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		fn(path, info)
+		return nil
+	})
+}
+
+// appendLine 在文件末尾追加一行。
+func appendLine(path string, line string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(file, line)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func cmdWatch(args []string) int {
+	flags := newFlagSet("watch")
+	output := Output{Quiet: true}
+	interval := flags.Duration("interval", time.Second, "扫描间隔")
+	settle := flags.Int("settle", 2, "文件大小和修改时间连续多少次扫描不变时上传")
+	recursive := flags.Bool("r", false, "同时监视子目录")
+	existing := flags.Bool("existing", false, "启动时上传目录中已有的文件，清单中未变化的文件不会重新上传")
+	manifestPath := flags.String("manifest", DataPath("manifest.json"), "清单文件，记录已上传文件的直链")
+	logPath := flags.String("log", "", "追加直链的日志文件")
+	retries := flags.Int("retries", 5, "每个文件最多尝试上传的次数，之后直到文件再次修改才重新上传")
+	hook := flags.String("exec", "", "上传后使用 shell 执行的命令，标准输入为上传结果的 JSON，同时设置 GODODO_URL 等环境变量")
+	flags.BoolVar(&output.JSON, "json", false, "每行输出一个 JSON 对象")
+	flags.StringVar(&output.Format, "format", "", "输出格式: "+strings.Join(Formats, "、")+"，或 text/template 模板")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 1 || *interval <= 0 || *settle < 1 || *retries < 1 {
+		flags.Usage()
+		return ExitUsage
+	}
+	if code := output.check(); code >= 0 {
+		return code
+	}
+	dir := flags.Arg(0)
+	if stat, err := os.Stat(dir); err != nil || !stat.IsDir() {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 目录不存在:", dir)
+		return ExitNotFound
+	}
+	manifest, err := LoadManifest(*manifestPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	watcher := &Watcher{Dir: dir, Recursive: *recursive, Settle: *settle, Retries: *retries}
+	for _, path := range []string{*manifestPath, *logPath, DataPath(HistoryFile), DataPath(QueueFile)} {
+		if path == "" {
+			continue
+		}
+		if absPath, err := filepath.Abs(path); err == nil {
+			watcher.Ignore = append(watcher.Ignore, absPath, absPath+".tmp")
+		}
+	}
+	if err = watcher.Init(*existing); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Fprintf(os.Stderr, "👀 正在监视 %s\n", dir)
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ExitOK
+		case <-ticker.C:
+		}
+		paths, err := watcher.Scan()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		}
+		for _, path := range paths {
+			if ctx.Err() != nil {
+				return ExitOK
+			}
+			if watchUpload(ctx, path, userInfo, manifest, output, *logPath, *hook) {
+				watcher.Done(path)
+			} else if ctx.Err() == nil && !watcher.Failed(path) {
+				fmt.Fprintf(os.Stderr, "⚠️ 已失败 %d 次，文件修改后再重新上传: %s\n", *retries, path)
+			}
+		}
+	}
+}
+
+// watchUpload 上传监视目录中的文件并记录直链，上传失败时返回 false。
+func watchUpload(ctx context.Context, path string, userInfo *UserInfo, manifest *Manifest, output Output, logPath string, hook string) bool {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return os.IsNotExist(err)
+	}
+	if _, ok := manifest.Lookup(path, stat); ok {
+		return true
+	}
+	result, err := UploadFileContext(ctx, path, userInfo, nil)
+	if err != nil {
+		if ctx.Err() == nil {
+			output.Error(path, err)
+		}
+		return false
+	}
+	output.Result(result)
+	manifest.Put(result, stat)
+	if err = manifest.Save(); err != nil {
+		fmt.Fprintln(os.Stderr, "⚠️ 清单保存失败:", err)
+	}
+	if logPath != "" {
+		format := output.Format
+		if format == "" {
+			format = "plain"
+		}
+		line, _ := FormatLink(result, format)
+		if err = appendLine(logPath, line); err != nil {
+			fmt.Fprintln(os.Stderr, "⚠️ 日志写入失败:", err)
+		}
+	}
+	if hook != "" {
+		// 与 hooks.json 中的命令钩子不同，标准输入为上传结果的 JSON，命令的输出写入标准输出。
+		event := &HookEvent{Event: HookUpload, Path: result.Path, Result: result, Time: time.Now()}
+		data, _ := json.Marshal(result)
+		if err = ShellHook(hook).exec(ctx, event, data, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️ 上传后命令执行失败: %s: %s\n", path, err)
+		}
+	}
+	return true
+}
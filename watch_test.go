@@ -0,0 +1,98 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data string) string {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	old := write("old.png", "old")
+	watcher := &Watcher{Dir: dir, Settle: 2, Ignore: []string{filepath.Join(dir, "links.txt")}}
+	if err := watcher.Init(false); err != nil {
+		t.Fatal(err)
+	}
+	a := write("a.png", "a")
+	write("b.png.crdownload", "b")
+	write(".hidden", "h")
+	write("links.txt", "x")
+	write("sub/c.png", "c")
+	scans := [][]string{}
+	for i := 0; i < 3; i++ {
+		paths, err := watcher.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		scans = append(scans, paths)
+	}
+	if want := [][]string{{}, {}, {a}}; !reflect.DeepEqual(scans, want) {
+		t.Fatalf("scans = %v, want %v", scans, want)
+	}
+	// 上传前仍然会返回，上传后直到再次修改才会返回。
+	if paths, _ := watcher.Scan(); !reflect.DeepEqual(paths, []string{a}) {
+		t.Errorf("Scan() before Done = %v", paths)
+	}
+	watcher.Done(a)
+	if paths, _ := watcher.Scan(); len(paths) != 0 {
+		t.Errorf("Scan() after Done = %v", paths)
+	}
+	write("old.png", "changed")
+	os.Chtimes(old, time.Now(), time.Now().Add(time.Minute))
+	watcher.Scan()
+	watcher.Scan()
+	if paths, _ := watcher.Scan(); !reflect.DeepEqual(paths, []string{old}) {
+		t.Errorf("Scan() after change = %v, want %v", paths, []string{old})
+	}
+
+	watcher = &Watcher{Dir: dir, Settle: 1, Recursive: true}
+	watcher.Init(true)
+	watcher.Scan()
+	paths, _ := watcher.Scan()
+	if want := []string{a, filepath.Join(dir, "links.txt"), old, filepath.Join(dir, "sub", "c.png")}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("recursive Scan() = %v, want %v", paths, want)
+	}
+}
+
+func TestWatcherFailed(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.png")
+	os.WriteFile(path, []byte("a"), 0644)
+	watcher := &Watcher{Dir: dir, Settle: 1, Retries: 2}
+	watcher.Init(true)
+	watcher.Scan()
+	if paths, _ := watcher.Scan(); !reflect.DeepEqual(paths, []string{path}) {
+		t.Fatalf("Scan() = %v", paths)
+	}
+	if !watcher.Failed(path) {
+		t.Fatal("first failure should retry")
+	}
+	if paths, _ := watcher.Scan(); len(paths) != 0 {
+		t.Fatalf("Scan() before retry time = %v", paths)
+	}
+	watcher.files[path].nextTry = time.Now()
+	if paths, _ := watcher.Scan(); !reflect.DeepEqual(paths, []string{path}) {
+		t.Fatalf("Scan() after retry time = %v", paths)
+	}
+	if watcher.Failed(path) {
+		t.Fatal("second failure should give up")
+	}
+	if paths, _ := watcher.Scan(); len(paths) != 0 {
+		t.Fatalf("Scan() after giving up = %v", paths)
+	}
+	os.WriteFile(path, []byte("changed"), 0644)
+	watcher.Scan()
+	if paths, _ := watcher.Scan(); !reflect.DeepEqual(paths, []string{path}) {
+		t.Fatalf("Scan() after change = %v", paths)
+	}
+}
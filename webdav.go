@@ -0,0 +1,498 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WebDAVIndexFile WebDAV 目录结构的索引文件名，位于 [DataPath] 指定的目录。
+const WebDAVIndexFile = "webdav-index.json"
+
+// sidecarExt 列表中为每个文件附带的快捷方式的扩展名，内容为文件直链。
+const sidecarExt = ".url"
+
+// WebDAVServer 以 WebDAV 提供的网络驱动器，写入的文件会上传到 DoDo。
+//
+// 目录结构保存在 [ObjectIndex] 中，重启后仍然可以列出。列表中每个文件都附带一个 .url 快捷方式，
+// 打开即可访问直链。读取文件时重定向到直链，Proxy 为 true 时由服务器转发文件内容。
+type WebDAVServer struct {
+	UserInfo *UserInfo
+	Index    *ObjectIndex
+	Proxy    bool
+	// Basic 认证的用户名和密码，为空时不认证。
+	Username, Password string
+}
+
+// ignoredName 判断是否为系统自动生成的文件，这类文件不会上传。
+func ignoredName(name string) bool {
+	return strings.HasPrefix(name, "._") || name == ".DS_Store" || name == "Thumbs.db" || name == "desktop.ini"
+}
+
+// ServeHTTP 处理 WebDAV 请求。
+func (s *WebDAVServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Username != "" || s.Password != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(username), []byte(s.Username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(s.Password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="gododo"`)
+			http.Error(w, "需要登录", http.StatusUnauthorized)
+			return
+		}
+	}
+	key := davKey(r.URL.Path)
+	switch r.Method {
+	case "OPTIONS":
+		w.Header().Set("DAV", "1, 2")
+		w.Header().Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, MKCOL, COPY, MOVE, PROPFIND, PROPPATCH, LOCK, UNLOCK")
+		w.Header().Set("MS-Author-Via", "DAV")
+	case "PROPFIND":
+		s.propfind(w, r, key)
+	case "PROPPATCH":
+		s.proppatch(w, r, key)
+	case "GET", "HEAD":
+		s.get(w, r, key)
+	case "PUT":
+		s.put(w, r, key)
+	case "MKCOL":
+		if s.Index.IsDir(key) || s.Index.Get(key) != nil {
+			http.Error(w, "已存在", http.StatusMethodNotAllowed)
+		} else if err := s.Index.MakeDir(key); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusCreated)
+		}
+	case "DELETE":
+		if ok, err := s.Index.Delete(key); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if !ok && !strings.HasSuffix(key, sidecarExt) {
+			http.Error(w, "不存在", http.StatusNotFound)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	case "COPY", "MOVE":
+		s.move(w, r, key)
+	case "LOCK":
+		s.lock(w, r)
+	case "UNLOCK":
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "不支持 "+r.Method, http.StatusMethodNotAllowed)
+	}
+}
+
+// davKey 将请求路径转换为索引中的键，根目录为空字符串。
+func davKey(p string) string {
+	return strings.Trim(path.Clean("/"+p), "/")
+}
+
+// davHref 将索引中的键转换为 URL 路径。
+func davHref(key string, dir bool) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	href := "/" + strings.Join(parts, "/")
+	if dir && key != "" {
+		href += "/"
+	}
+	return href
+}
+
+// sidecar 返回快捷方式对应的对象，key 不是快捷方式时返回 nil。
+func (s *WebDAVServer) sidecar(key string) *IndexObject {
+	target, ok := strings.CutSuffix(key, sidecarExt)
+	if !ok {
+		return nil
+	}
+	object := s.Index.Get(target)
+	if object == nil || object.URL == "" {
+		return nil
+	}
+	return object
+}
+
+// sidecarContent 返回 Windows 和 macOS 都可以打开的 Internet 快捷方式。
+func sidecarContent(object *IndexObject) string {
+	return "[InternetShortcut]\r\nURL=" + object.URL + "\r\n"
+}
+
+type davMultistatus struct {
+	XMLName   xml.Name      `xml:"D:multistatus"`
+	Namespace string        `xml:"xmlns:D,attr"`
+	Responses []davResponse `xml:"D:response"`
+}
+
+type davResponse struct {
+	Href     string      `xml:"D:href"`
+	Propstat davPropstat `xml:"D:propstat"`
+}
+
+type davPropstat struct {
+	Prop   davProp `xml:"D:prop"`
+	Status string  `xml:"D:status"`
+}
+
+type davProp struct {
+	DisplayName   string       `xml:"D:displayname,omitempty"`
+	ResourceType  *davResource `xml:"D:resourcetype"`
+	ContentLength *int64       `xml:"D:getcontentlength,omitempty"`
+	ContentType   string       `xml:"D:getcontenttype,omitempty"`
+	LastModified  string       `xml:"D:getlastmodified,omitempty"`
+	ETag          string       `xml:"D:getetag,omitempty"`
+	Extra         []davAnyProp `xml:",any"`
+}
+
+type davResource struct {
+	Collection *struct{} `xml:"D:collection"`
+}
+
+type davAnyProp struct {
+	XMLName xml.Name
+}
+
+func davDir(key string) davResponse {
+	return davResponse{
+		Href: davHref(key, true),
+		Propstat: davPropstat{
+			Prop:   davProp{DisplayName: path.Base("/" + key), ResourceType: &davResource{Collection: &struct{}{}}},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+func davFile(key string, size int64, contentType string, modTime time.Time, etag string) davResponse {
+	return davResponse{
+		Href: davHref(key, false),
+		Propstat: davPropstat{
+			Prop: davProp{
+				DisplayName:   path.Base(key),
+				ResourceType:  &davResource{},
+				ContentLength: &size,
+				ContentType:   contentType,
+				LastModified:  modTime.UTC().Format(http.TimeFormat),
+				ETag:          etag,
+			},
+			Status: "HTTP/1.1 200 OK",
+		},
+	}
+}
+
+func davObject(key string, object *IndexObject) davResponse {
+	etag := ""
+	if object.MD5 != "" {
+		etag = `"` + object.MD5 + `"`
+	}
+	return davFile(key, object.Size, MediaType(path.Ext(key)), object.Time, etag)
+}
+
+func davSidecar(key string, object *IndexObject) davResponse {
+	return davFile(key+sidecarExt, int64(len(sidecarContent(object))), "application/internet-shortcut", object.Time, "")
+}
+
+func writeMultistatus(w http.ResponseWriter, responses []davResponse) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(xml.Header))
+	xml.NewEncoder(w).Encode(davMultistatus{Namespace: "DAV:", Responses: responses})
+}
+
+// propfind 返回文件或目录的属性，Depth 不为 0 时同时返回目录中的子目录、文件和快捷方式。
+func (s *WebDAVServer) propfind(w http.ResponseWriter, r *http.Request, key string) {
+	io.Copy(io.Discard, r.Body)
+	if object := s.Index.Get(key); object != nil {
+		writeMultistatus(w, []davResponse{davObject(key, object)})
+		return
+	}
+	if object := s.sidecar(key); object != nil {
+		writeMultistatus(w, []davResponse{davSidecar(strings.TrimSuffix(key, sidecarExt), object)})
+		return
+	}
+	if !s.Index.IsDir(key) {
+		http.Error(w, "不存在", http.StatusNotFound)
+		return
+	}
+	responses := []davResponse{davDir(key)}
+	if r.Header.Get("Depth") != "0" {
+		prefix := ""
+		if key != "" {
+			prefix = key + "/"
+		}
+		dirs, objects := s.Index.List(key)
+		for _, name := range dirs {
+			responses = append(responses, davDir(prefix+name))
+		}
+		for _, name := range objects {
+			object := s.Index.Get(prefix + name)
+			if object == nil {
+				continue
+			}
+			responses = append(responses, davObject(prefix+name, object))
+			if object.URL != "" {
+				responses = append(responses, davSidecar(prefix+name, object))
+			}
+		}
+	}
+	writeMultistatus(w, responses)
+}
+
+// proppatch 不保存属性，只返回成功，使客户端可以正常设置修改时间等属性。
+func (s *WebDAVServer) proppatch(w http.ResponseWriter, r *http.Request, key string) {
+	if s.Index.Get(key) == nil && !s.Index.IsDir(key) {
+		http.Error(w, "不存在", http.StatusNotFound)
+		return
+	}
+	var body struct {
+		Set []struct {
+			Prop struct {
+				Props []davAnyProp `xml:",any"`
+			} `xml:"prop"`
+		} `xml:"set"`
+		Remove []struct {
+			Prop struct {
+				Props []davAnyProp `xml:",any"`
+			} `xml:"prop"`
+		} `xml:"remove"`
+	}
+	xml.NewDecoder(r.Body).Decode(&body)
+	props := []davAnyProp{}
+	for _, set := range body.Set {
+		props = append(props, set.Prop.Props...)
+	}
+	for _, remove := range body.Remove {
+		props = append(props, remove.Prop.Props...)
+	}
+	writeMultistatus(w, []davResponse{{
+		Href:     davHref(key, false),
+		Propstat: davPropstat{Prop: davProp{Extra: props}, Status: "HTTP/1.1 200 OK"},
+	}})
+}
+
+func (s *WebDAVServer) get(w http.ResponseWriter, r *http.Request, key string) {
+	if object := s.sidecar(key); object != nil {
+		content := sidecarContent(object)
+		w.Header().Set("Content-Type", "application/internet-shortcut")
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		io.WriteString(w, content)
+		return
+	}
+	object := s.Index.Get(key)
+	if object == nil {
+		if s.Index.IsDir(key) {
+			s.listHTML(w, key)
+			return
+		}
+		http.Error(w, "不存在", http.StatusNotFound)
+		return
+	}
+	if object.URL == "" {
+		// 客户端在写入内容前创建的空文件。
+		w.Header().Set("Content-Length", "0")
+		return
+	}
+	if !s.Proxy {
+		http.Redirect(w, r, object.URL, http.StatusFound)
+		return
+	}
+	request, err := http.NewRequestWithContext(r.Context(), r.Method, object.URL, nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if value := r.Header.Get("Range"); value != "" {
+		request.Header.Set("Range", value)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+	for _, name := range []string{"Content-Type", "Content-Length", "Content-Range", "Accept-Ranges", "Last-Modified", "ETag"} {
+		if value := response.Header.Get(name); value != "" {
+			w.Header().Set(name, value)
+		}
+	}
+	w.WriteHeader(response.StatusCode)
+	io.Copy(w, response.Body)
+}
+
+// listHTML 在浏览器中访问目录时，输出包含直链的文件列表。
+func (s *WebDAVServer) listHTML(w http.ResponseWriter, key string) {
+	prefix := ""
+	if key != "" {
+		prefix = key + "/"
+	}
+	dirs, objects := s.Index.List(key)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!doctype html>\n<meta charset=\"utf-8\">\n<title>/%s</title>\n<h1>/%s</h1>\n<ul>\n", html.EscapeString(key), html.EscapeString(key))
+	if key != "" {
+		fmt.Fprintf(w, "<li><a href=\"%s\">../</a></li>\n", html.EscapeString(davHref(path.Dir("/" + key)[1:], true)))
+	}
+	for _, name := range dirs {
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s/</a></li>\n", html.EscapeString(davHref(prefix+name, true)), html.EscapeString(name))
+	}
+	for _, name := range objects {
+		object := s.Index.Get(prefix + name)
+		if object == nil || object.URL == "" {
+			continue
+		}
+		fmt.Fprintf(w, "<li><a href=\"%s\">%s</a> (%s)</li>\n", html.EscapeString(object.URL), html.EscapeString(name), HumanSize(object.Size))
+	}
+	fmt.Fprintln(w, "</ul>")
+}
+
+func (s *WebDAVServer) put(w http.ResponseWriter, r *http.Request, key string) {
+	name := path.Base(key)
+	if key == "" || s.Index.IsDir(key) {
+		http.Error(w, "不能写入目录", http.StatusMethodNotAllowed)
+		return
+	}
+	if dir := path.Dir(key); dir != "." && !s.Index.IsDir(dir) {
+		http.Error(w, "目录不存在", http.StatusConflict)
+		return
+	}
+	if strings.HasSuffix(key, sidecarExt) && s.Index.Get(strings.TrimSuffix(key, sidecarExt)) != nil {
+		http.Error(w, "快捷方式由服务器生成，不能写入", http.StatusForbidden)
+		return
+	}
+	if ignoredName(name) {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+	created := s.Index.Get(key) == nil
+	body := bufio.NewReader(r.Body)
+	var object *IndexObject
+	if _, err := body.Peek(1); err == io.EOF {
+		// 有的客户端先创建空文件再写入内容，空文件不上传。
+		object = &IndexObject{Time: time.Now()}
+	} else {
+		result, err := UploadReader(r.Context(), name, body, s.UserInfo, nil)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		object = &IndexObject{URL: result.URL, MD5: result.MD5, Size: result.Size, Time: result.Time}
+		fmt.Fprintf(os.Stderr, "/%s -> %s\n", key, result.URL)
+	}
+	if err := s.Index.Put(key, object); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if object.MD5 != "" {
+		w.Header().Set("ETag", `"`+object.MD5+`"`)
+	}
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *WebDAVServer) move(w http.ResponseWriter, r *http.Request, key string) {
+	destination, err := url.Parse(r.Header.Get("Destination"))
+	if err != nil || destination.Path == "" {
+		http.Error(w, "Destination 头格式错误", http.StatusBadRequest)
+		return
+	}
+	target := davKey(destination.Path)
+	if key == "" || target == "" || target == key || strings.HasPrefix(target, key+"/") {
+		http.Error(w, "不能移动到该位置", http.StatusForbidden)
+		return
+	}
+	if dir := path.Dir(target); dir != "." && !s.Index.IsDir(dir) {
+		http.Error(w, "目录不存在", http.StatusConflict)
+		return
+	}
+	exists := s.Index.Get(target) != nil || s.Index.IsDir(target)
+	if exists && r.Header.Get("Overwrite") == "F" {
+		http.Error(w, "目标已存在", http.StatusPreconditionFailed)
+		return
+	}
+	ok, err := s.Index.Move(key, target, r.Method == "COPY")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if !ok {
+		http.Error(w, "不存在", http.StatusNotFound)
+		return
+	}
+	if exists {
+		w.WriteHeader(http.StatusNoContent)
+	} else {
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+// lock 返回一个不会被检查的锁，Windows 和 macOS 只有在支持锁时才允许写入。
+func (s *WebDAVServer) lock(w http.ResponseWriter, r *http.Request) {
+	io.Copy(io.Discard, r.Body)
+	token := fmt.Sprintf("opaquelocktoken:gododo-%d", time.Now().UnixNano())
+	timeout := r.Header.Get("Timeout")
+	if timeout == "" {
+		timeout = "Second-3600"
+	}
+	w.Header().Set("Lock-Token", "<"+token+">")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	fmt.Fprintf(w, `%s<D:prop xmlns:D="DAV:"><D:lockdiscovery><D:activelock>`+
+		`<D:locktype><D:write/></D:locktype><D:lockscope><D:exclusive/></D:lockscope>`+
+		`<D:depth>infinity</D:depth><D:timeout>%s</D:timeout>`+
+		`<D:locktoken><D:href>%s</D:href></D:locktoken>`+
+		`<D:lockroot><D:href>%s</D:href></D:lockroot>`+
+		`</D:activelock></D:lockdiscovery></D:prop>`, xml.Header, html.EscapeString(timeout), token, html.EscapeString(r.URL.Path))
+}
+
+func cmdWebDAV(args []string) int {
+	flags := newFlagSet("webdav")
+	addr := flags.String("addr", "127.0.0.1:8687", "监听地址")
+	user := flags.String("user", os.Getenv("GODODO_WEBDAV_USER"), "Basic 认证的用户名和密码，格式为 name:password")
+	proxy := flags.Bool("proxy", false, "读取文件时由服务器转发内容，而不是重定向到直链")
+	indexPath := flags.String("index", DataPath(WebDAVIndexFile), "目录结构索引文件")
+	if code := parseFlags(flags, args); code >= 0 {
+		return code
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+	server := &WebDAVServer{Proxy: *proxy}
+	if *user != "" {
+		var ok bool
+		server.Username, server.Password, ok = strings.Cut(*user, ":")
+		if !ok {
+			fmt.Fprintln(os.Stderr, "❗ 错误: --user 的格式应为 name:password")
+			return ExitUsage
+		}
+	} else if !isLoopback(*addr) {
+		fmt.Fprintln(os.Stderr, "❗ 错误: 监听非本机地址时必须设置 --user")
+		return ExitUsage
+	}
+	index, err := LoadObjectIndex(*indexPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	server.Index = index
+	userInfo, code := requireUserInfo()
+	if userInfo == nil {
+		return code
+	}
+	server.UserInfo = userInfo
+	fmt.Fprintf(os.Stderr, "👂 正在监听 http://%s\n", *addr)
+	if err = newHTTPServer(*addr, server).ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, "❗ 错误:", err)
+		return ExitError
+	}
+	return ExitOK
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWebDAVServer(t *testing.T) {
+	index, _ := LoadObjectIndex(filepath.Join(t.TempDir(), WebDAVIndexFile))
+	index.Put("设计/a b.png", &IndexObject{URL: "https://files.imdodo.com/dodo/abc.png", MD5: "abc", Size: 10})
+	server := &WebDAVServer{Index: index, Username: "u", Password: "p"}
+	tests := []struct {
+		method, path string
+		header       map[string]string
+		status       int
+		want         string
+	}{
+		{"PROPFIND", "/", nil, http.StatusUnauthorized, ""},
+		{"MKCOL", "/空目录", nil, http.StatusCreated, ""},
+		{"MKCOL", "/空目录", nil, http.StatusMethodNotAllowed, ""},
+		{"PUT", "/missing/a.txt", nil, http.StatusConflict, ""},
+		{"PUT", "/空目录/new.txt", nil, http.StatusCreated, ""},
+		{"PROPFIND", "/", map[string]string{"Depth": "1"}, http.StatusMultiStatus, `<D:href>/%E7%A9%BA%E7%9B%AE%E5%BD%95/</D:href>`},
+		{"PROPFIND", "/设计/", map[string]string{"Depth": "1"}, http.StatusMultiStatus, `<D:href>/%E8%AE%BE%E8%AE%A1/a%20b.png.url</D:href>`},
+		{"PROPFIND", "/设计/a b.png", map[string]string{"Depth": "0"}, http.StatusMultiStatus, `<D:getetag>&#34;abc&#34;</D:getetag>`},
+		{"GET", "/设计/a b.png", nil, http.StatusFound, "https://files.imdodo.com/dodo/abc.png"},
+		{"GET", "/设计/a b.png.url", nil, http.StatusOK, "URL=https://files.imdodo.com/dodo/abc.png\r\n"},
+		{"PUT", "/设计/a b.png.url", nil, http.StatusForbidden, ""},
+		{"GET", "/设计/", nil, http.StatusOK, `<a href="https://files.imdodo.com/dodo/abc.png">a b.png</a>`},
+		{"MOVE", "/设计/a b.png", map[string]string{"Destination": "http://example.com/%E7%A9%BA%E7%9B%AE%E5%BD%95/b.png"}, http.StatusCreated, ""},
+		{"GET", "/空目录/b.png", nil, http.StatusFound, "https://files.imdodo.com/dodo/abc.png"},
+		{"PROPFIND", "/设计", map[string]string{"Depth": "1"}, http.StatusMultiStatus, "<D:collection>"},
+		{"DELETE", "/设计", nil, http.StatusNoContent, ""},
+		{"PROPFIND", "/设计", nil, http.StatusNotFound, ""},
+		{"LOCK", "/空目录/b.png", nil, http.StatusOK, "opaquelocktoken:"},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(test.method, "http://example.com"+strings.ReplaceAll(test.path, " ", "%20"), nil)
+		if test.status != http.StatusUnauthorized {
+			request.SetBasicAuth("u", "p")
+		}
+		for name, value := range test.header {
+			request.Header.Set(name, value)
+		}
+		recorder := httptest.NewRecorder()
+		server.ServeHTTP(recorder, request)
+		got := recorder.Body.String() + recorder.Header().Get("Location")
+		if recorder.Code != test.status || !strings.Contains(got, test.want) {
+			t.Errorf("%s %s = %d %s", test.method, test.path, recorder.Code, got)
+		}
+	}
+	loaded, _ := LoadObjectIndex(index.path)
+	if loaded.Get("空目录/b.png") == nil || loaded.Get("空目录/new.txt") == nil || loaded.IsDir("设计") {
+		t.Fatalf("%+v", loaded)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed web
+var webFiles embed.FS
+
+// webFS 网页界面的静态文件，index.html 位于根目录。
+var webFS, _ = fs.Sub(webFiles, "web")
+
+var uploadIDPattern = regexp.MustCompile(`^[0-9A-Za-z_-]{1,64}$`)
+
+// ServerEvent 通过 SSE 推送给网页的上传事件。
+type ServerEvent struct {
+	// 网页为每个文件生成的 ID。
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	// progress 表示正在上传到 DoDo，done 表示上传完成，error 表示上传失败。
+	Stage  string        `json:"stage"`
+	Sent   int64         `json:"sent,omitempty"`
+	Total  int64         `json:"total,omitempty"`
+	Result *UploadResult `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// eventHub 将上传事件广播给全部 SSE 连接，处理不过来的连接会丢弃事件。
+type eventHub struct {
+	mu      sync.Mutex
+	clients map[chan ServerEvent]bool
+}
+
+func (h *eventHub) subscribe() chan ServerEvent {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients == nil {
+		h.clients = map[chan ServerEvent]bool{}
+	}
+	ch := make(chan ServerEvent, 64)
+	h.clients[ch] = true
+	return ch
+}
+
+func (h *eventHub) unsubscribe(ch chan ServerEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.clients, ch)
+}
+
+func (h *eventHub) publish(event ServerEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.clients {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// progress 返回发布上传进度事件的回调，进度每增加 1% 发布一次。
+func (h *eventHub) progress(id string, name string) func(sent int64, total int64) {
+	if id == "" {
+		return nil
+	}
+	last := int64(-1)
+	return func(sent int64, total int64) {
+		percent := int64(100)
+		if total > 0 {
+			percent = sent * 100 / total
+		}
+		if percent == last {
+			return
+		}
+		last = percent
+		h.publish(ServerEvent{ID: id, Name: name, Stage: "progress", Sent: sent, Total: total})
+	}
+}
+
+// handleEvents 以 Server-Sent Events 推送上传事件。
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持 SSE", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-ch:
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+		case <-ticker.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handleHistory 返回本次运行中上传的文件，q 参数按文件名、MD5 或直链过滤，最新的在前。
+func (s *Server) handleHistory(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("q"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	results := []*UploadResult{}
+	for i := len(s.results) - 1; i >= 0; i-- {
+		result := s.results[i]
+		text := strings.ToLower(result.Base + " " + result.MD5 + " " + result.URL)
+		if query == "" || strings.Contains(text, query) {
+			results = append(results, result)
+		}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// addResult 记录上传结果并发布完成事件。
+func (s *Server) addResult(id string, result *UploadResult) {
+	s.mu.Lock()
+	s.results = append(s.results, result)
+	s.mu.Unlock()
+	if id != "" {
+		s.events.publish(ServerEvent{ID: id, Name: result.Base, Stage: "done", Result: result})
+	}
+}